@@ -0,0 +1,33 @@
+package operator
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+// WrapHTTP rejects requests without the `Authorization: Bearer {token}` header.
+//
+// Operator requests must not be wrapped by clientinfo.WrapHTTP, which would take the token for a device ID and put it
+// into logs and metrics.
+func WrapHTTP(next http.HandlerFunc, token string, l zerolog.Logger) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		reqToken, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(reqToken), []byte(token)) != 1 {
+			metrics.HTTPServerRequest(req, req.URL.Path)(http.StatusUnauthorized)
+			l.Warn().
+				Err(errors.New("invalid token")).
+				Str("req_method", req.Method).
+				Str("req_uri", req.RequestURI).
+				Msg("operator request rejected")
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	}
+}
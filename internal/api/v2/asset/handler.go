@@ -0,0 +1,78 @@
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/signedurl"
+	"github.com/ashep/d5y/internal/update"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+type Handler struct {
+	updSvc *update.Service
+	signer *signedurl.Signer
+	l      zerolog.Logger
+}
+
+func New(updSvc *update.Service, signer *signedurl.Signer, l zerolog.Logger) *Handler {
+	return &Handler{
+		updSvc: updSvc,
+		signer: signer,
+		l:      l,
+	}
+}
+
+// Handle serves an asset by a signed link, redirecting to a temporary URL of the asset origin if possible.
+func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) {
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, "/v2/firmware/asset")
+
+	if req.Method != http.MethodGet {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("firmware asset request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, name, ref, err := h.signer.Verify(req.URL.Query())
+	if err != nil {
+		m(http.StatusForbidden)
+		l.Warn().Err(err).Msg("firmware asset request failed")
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	l.Info().Str("repo", owner+"/"+name).Str("ref", ref).Msg("firmware asset request")
+
+	u, rc, err := h.updSvc.OpenAsset(req.Context(), owner, name, ref)
+	if err != nil {
+		m(http.StatusBadGateway)
+		l.Error().Err(fmt.Errorf("open asset: %w", err)).Msg("firmware asset request failed")
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	if u != "" {
+		m(http.StatusFound)
+		http.Redirect(rw, req, u, http.StatusFound)
+		return
+	}
+
+	defer rc.Close() //nolint:errcheck // ok
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(rw, rc); err != nil {
+		m(http.StatusInternalServerError)
+		l.Error().Err(fmt.Errorf("write response: %w", err)).Msg("firmware asset request failed")
+		return
+	}
+
+	m(http.StatusOK)
+}
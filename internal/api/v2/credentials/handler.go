@@ -0,0 +1,99 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/devauth"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+// Handler provisions and revokes device credentials, for operators.
+type Handler struct {
+	auth *devauth.Service
+	l    zerolog.Logger
+}
+
+// New creates the handler. Requests must be authenticated by operator.WrapHTTP.
+func New(auth *devauth.Service, l zerolog.Logger) *Handler {
+	return &Handler{
+		auth: auth,
+		l:    l,
+	}
+}
+
+// Handle issues a new credential of the `id` device on POST, replacing the previous one, and revokes it on DELETE.
+func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) {
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, "/v2/devices/credentials")
+
+	if req.Method != http.MethodPost && req.Method != http.MethodDelete {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("device credentials request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	devID := req.URL.Query().Get("id")
+	if devID == "" {
+		m(http.StatusBadRequest)
+		l.Warn().Err(errors.New("empty device id")).Msg("device credentials request failed")
+		rpcutil.WriteBadRequest(rw, "invalid id", l)
+		return
+	}
+
+	if req.Method == http.MethodDelete {
+		err := h.auth.Revoke(req.Context(), devID)
+		if errors.Is(err, devauth.ErrNotFound) {
+			m(http.StatusNotFound)
+			l.Warn().Err(err).Str("device_id", devID).Msg("device credentials request failed")
+			rpcutil.WriteNotFound(rw, err.Error(), l)
+			return
+		} else if err != nil {
+			m(http.StatusInternalServerError)
+			rpcutil.WriteInternalServerError(rw, err, l)
+			return
+		}
+
+		m(http.StatusNoContent)
+		l.Info().Str("device_id", devID).Msg("device credential revoked")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	issued, err := h.auth.Provision(req.Context(), devID)
+	if errors.Is(err, devauth.ErrInvalidDeviceID) {
+		m(http.StatusBadRequest)
+		l.Warn().Err(err).Msg("device credentials request failed")
+		rpcutil.WriteBadRequest(rw, "invalid id", l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	b, err := json.Marshal(issued)
+	if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, fmt.Errorf("marshal response: %w", err), l)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(b); err != nil {
+		m(http.StatusInternalServerError)
+		l.Error().Err(fmt.Errorf("write response: %w", err)).Msg("device credentials request failed")
+		return
+	}
+
+	m(http.StatusOK)
+	l.Info().Str("device_id", devID).Msg("device credential provisioned")
+}
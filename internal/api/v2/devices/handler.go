@@ -0,0 +1,99 @@
+package devices
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/inventory"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+type Response struct {
+	Devices []inventory.Device `json:"devices"`
+}
+
+// Handler lists devices of the inventory, for operators.
+type Handler struct {
+	inv *inventory.Registry
+	l   zerolog.Logger
+}
+
+// New creates the handler. Requests must be authenticated by operator.WrapHTTP.
+func New(inv *inventory.Registry, l zerolog.Logger) *Handler {
+	return &Handler{
+		inv: inv,
+		l:   l,
+	}
+}
+
+// Handle responds with the devices matching the query.
+//
+// Optional query parameters: `version`, a version constraint, e.g. `0.3.x`; `hardware`; `seen_within` and
+// `not_seen_for`, durations limiting the time since devices were last seen, e.g. `24h`.
+func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) {
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, "/v2/devices")
+
+	if req.Method != http.MethodGet {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("devices request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := req.URL.Query()
+	l.Info().Str("query", q.Encode()).Msg("devices request")
+
+	invQ := inventory.Query{
+		Version:  q.Get("version"),
+		Hardware: q.Get("hardware"),
+	}
+
+	for param, dst := range map[string]*time.Duration{"seen_within": &invQ.MaxAge, "not_seen_for": &invQ.MinAge} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			m(http.StatusBadRequest)
+			l.Warn().Err(fmt.Errorf("invalid %s", param)).Msg("devices request failed")
+			rpcutil.WriteBadRequest(rw, "invalid "+param, l)
+			return
+		}
+
+		*dst = d
+	}
+
+	devs, err := h.inv.Find(invQ, time.Now())
+	if err != nil {
+		m(http.StatusBadRequest)
+		l.Warn().Err(err).Msg("devices request failed")
+		rpcutil.WriteBadRequest(rw, err.Error(), l)
+		return
+	}
+
+	b, err := json.Marshal(Response{Devices: devs})
+	if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, fmt.Errorf("marshal response: %w", err), l)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(b); err != nil {
+		m(http.StatusInternalServerError)
+		l.Error().Err(fmt.Errorf("write response: %w", err)).Msg("devices request failed")
+		return
+	}
+
+	m(http.StatusOK)
+}
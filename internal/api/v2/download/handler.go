@@ -0,0 +1,99 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/mirror"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+const pathPrefix = "/v2/firmware/download/"
+
+var sumRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type Handler struct {
+	mirror *mirror.Mirror
+	l      zerolog.Logger
+}
+
+func New(m *mirror.Mirror, l zerolog.Logger) *Handler {
+	return &Handler{
+		mirror: m,
+		l:      l,
+	}
+}
+
+// Handle serves a mirrored asset by its checksum. Range and conditional requests are supported.
+func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) {
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, pathPrefix)
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("firmware download request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sum := strings.ToLower(strings.TrimPrefix(req.URL.Path, pathPrefix))
+	if !sumRe.MatchString(sum) {
+		m(http.StatusBadRequest)
+		l.Warn().Err(errors.New("invalid checksum")).Msg("firmware download request failed")
+		rpcutil.WriteBadRequest(rw, "invalid checksum", l)
+		return
+	}
+
+	l.Info().Str("sha256", sum).Str("range", req.Header.Get("Range")).Msg("firmware download request")
+
+	f, err := h.mirror.Open(req.Context(), sum)
+	if u := h.mirror.OriginURL(sum); errors.Is(err, mirror.ErrTooLarge) && u != "" {
+		// Devices may have been offered the mirror URL before the asset was found too large
+		m(http.StatusFound)
+		l.Info().Str("location", u).Msg("asset too large to mirror, redirecting to origin")
+		http.Redirect(rw, req, u, http.StatusFound)
+		return
+	} else if errors.Is(err, mirror.ErrNotFound) || errors.Is(err, mirror.ErrTooLarge) {
+		m(http.StatusNotFound)
+		l.Warn().Err(err).Msg("firmware download request failed")
+		rpcutil.WriteNotFound(rw, err.Error(), l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, fmt.Errorf("open asset: %w", err), l)
+		return
+	}
+
+	defer f.Close() //nolint:errcheck // ok
+
+	fi, err := f.Stat()
+	if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, fmt.Errorf("stat asset: %w", err), l)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	rw.Header().Set("ETag", `"`+sum+`"`)
+	rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	sw := &statusWriter{ResponseWriter: rw, status: http.StatusOK}
+	http.ServeContent(sw, req, sum, fi.ModTime(), f)
+	m(sw.status)
+}
+
+// statusWriter remembers the status code written by http.ServeContent.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,126 @@
+package halts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/report"
+	"github.com/ashep/d5y/internal/update"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+type Response struct {
+	Owner       string  `json:"owner"`
+	Name        string  `json:"name"`
+	Version     string  `json:"version"`
+	Halted      bool    `json:"halted"`
+	Devices     int     `json:"devices"`
+	Installed   int     `json:"installed"`
+	Failed      int     `json:"failed"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// Handler shows and lifts halts of release rollouts, for operators.
+type Handler struct {
+	reportSvc *report.Service
+	updSvc    *update.Service
+	l         zerolog.Logger
+}
+
+// New creates the handler. Requests must be authenticated by operator.WrapHTTP.
+func New(reportSvc *report.Service, updSvc *update.Service, l zerolog.Logger) *Handler {
+	return &Handler{
+		reportSvc: reportSvc,
+		updSvc:    updSvc,
+		l:         l,
+	}
+}
+
+// Handle responds with report statistics of the `version` release of the `app=owner:name` app on GET, and resumes its
+// rollout on DELETE, forgetting the reports.
+func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) {
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, "/v2/firmware/halts")
+
+	if req.Method != http.MethodGet && req.Method != http.MethodDelete {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("firmware halts request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := req.URL.Query()
+
+	owner, name, err := h.updSvc.ResolveApp(strings.Replace(q.Get("app"), ":", "/", 1), "")
+	if errors.Is(err, update.ErrAppNotFound) {
+		m(http.StatusNotFound)
+		l.Warn().Err(err).Msg("firmware halts request failed")
+		rpcutil.WriteNotFound(rw, err.Error(), l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	ver, err := semver.NewVersion(q.Get("version"))
+	if err != nil {
+		m(http.StatusBadRequest)
+		l.Warn().Err(errors.New("invalid version")).Msg("firmware halts request failed")
+		rpcutil.WriteBadRequest(rw, "invalid version", l)
+		return
+	}
+
+	if req.Method == http.MethodDelete {
+		if err := h.reportSvc.Resume(req.Context(), owner, name, ver.String()); err != nil {
+			m(http.StatusInternalServerError)
+			rpcutil.WriteInternalServerError(rw, err, l)
+			return
+		}
+
+		m(http.StatusNoContent)
+		l.Info().Str("app", owner+"/"+name).Str("version", ver.String()).Msg("firmware rollout resumed")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	st, halted, err := h.reportSvc.Stats(req.Context(), owner, name, ver.String())
+	if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	b, err := json.Marshal(Response{
+		Owner:       owner,
+		Name:        name,
+		Version:     ver.String(),
+		Halted:      halted,
+		Devices:     st.Devices,
+		Installed:   st.Installed,
+		Failed:      st.Failed,
+		FailureRate: st.FailureRate(),
+	})
+	if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, fmt.Errorf("marshal response: %w", err), l)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(b); err != nil {
+		m(http.StatusInternalServerError)
+		l.Error().Err(fmt.Errorf("write response: %w", err)).Msg("firmware halts request failed")
+		return
+	}
+
+	m(http.StatusOK)
+}
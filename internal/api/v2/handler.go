@@ -2,28 +2,89 @@ package v2
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/rs/zerolog"
 
+	asseth "github.com/ashep/d5y/internal/api/v2/asset"
+	credentialsh "github.com/ashep/d5y/internal/api/v2/credentials"
+	devicesh "github.com/ashep/d5y/internal/api/v2/devices"
+	downloadh "github.com/ashep/d5y/internal/api/v2/download"
+	haltsh "github.com/ashep/d5y/internal/api/v2/halts"
+	hookh "github.com/ashep/d5y/internal/api/v2/hook"
+	releasesh "github.com/ashep/d5y/internal/api/v2/releases"
+	reporth "github.com/ashep/d5y/internal/api/v2/report"
 	timeh "github.com/ashep/d5y/internal/api/v2/time"
 	updateh "github.com/ashep/d5y/internal/api/v2/update"
 	weatherh "github.com/ashep/d5y/internal/api/v2/weather"
+	"github.com/ashep/d5y/internal/devauth"
+	"github.com/ashep/d5y/internal/inventory"
+	"github.com/ashep/d5y/internal/mirror"
+	"github.com/ashep/d5y/internal/report"
+	"github.com/ashep/d5y/internal/signature"
+	"github.com/ashep/d5y/internal/signedurl"
 	"github.com/ashep/d5y/internal/update"
 	"github.com/ashep/d5y/internal/weatherapi"
 )
 
 type Handler struct {
-	time    *timeh.Handler
-	weather *weatherh.Handler
-	update  *updateh.Handler
+	time     *timeh.Handler
+	weather  *weatherh.Handler
+	update   *updateh.Handler
+	report   *reporth.Handler
+	download *downloadh.Handler
+	ghHook   *hookh.GitHubHandler
+	asset    *asseth.Handler
+	releases *releasesh.Handler
+	devices  *devicesh.Handler
+	creds    *credentialsh.Handler
+	halts    *haltsh.Handler
 }
 
-func New(wAPI *weatherapi.Service, updSvc *update.Service, l zerolog.Logger) *Handler {
-	return &Handler{
+func New(
+	wAPI *weatherapi.Service,
+	updSvc *update.Service,
+	reportSvc *report.Service,
+	inv *inventory.Registry,
+	auth *devauth.Service,
+	mrr *mirror.Mirror,
+	linker *signedurl.Signer,
+	signer *signature.Signer,
+	offerTTL time.Duration,
+	ghHookSecret string,
+	operatorToken string,
+	l zerolog.Logger,
+) *Handler {
+	h := &Handler{
 		time:    timeh.New(wAPI, l.With().Str("handler", "time").Logger()),
 		weather: weatherh.New(wAPI, l.With().Str("handler", "weather").Logger()),
-		update:  updateh.New(updSvc, l),
+		update:  updateh.New(updSvc, signer, offerTTL, l),
+		report:  reporth.New(reportSvc, updSvc, inv, l.With().Str("handler", "report").Logger()),
 	}
+
+	if mrr != nil {
+		h.download = downloadh.New(mrr, l.With().Str("handler", "download").Logger())
+	}
+
+	if linker != nil {
+		h.asset = asseth.New(updSvc, linker, l.With().Str("handler", "asset").Logger())
+	}
+
+	if operatorToken != "" {
+		h.releases = releasesh.New(updSvc, l.With().Str("handler", "releases").Logger())
+		h.devices = devicesh.New(inv, l.With().Str("handler", "devices").Logger())
+		h.halts = haltsh.New(reportSvc, updSvc, l.With().Str("handler", "halts").Logger())
+
+		if auth != nil {
+			h.creds = credentialsh.New(auth, l.With().Str("handler", "credentials").Logger())
+		}
+	}
+
+	if ghHookSecret != "" {
+		h.ghHook = hookh.NewGitHub(updSvc, ghHookSecret, l.With().Str("handler", "github_hook").Logger())
+	}
+
+	return h
 }
 
 func (h *Handler) HandleTime(w http.ResponseWriter, r *http.Request) {
@@ -37,3 +98,79 @@ func (h *Handler) HandleWeather(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	h.update.Handle(w, r)
 }
+
+func (h *Handler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	h.report.Handle(w, r)
+}
+
+// HandleDownload serves mirrored assets; it responds with 404 if the mirror is off.
+func (h *Handler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	if h.download == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.download.Handle(w, r)
+}
+
+// HandleGitHubHook receives GitHub webhooks; it responds with 404 if no webhook secret is configured.
+func (h *Handler) HandleGitHubHook(w http.ResponseWriter, r *http.Request) {
+	if h.ghHook == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.ghHook.Handle(w, r)
+}
+
+// HandleAsset serves assets by signed links; it responds with 404 if links are off.
+func (h *Handler) HandleAsset(w http.ResponseWriter, r *http.Request) {
+	if h.asset == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.asset.Handle(w, r)
+}
+
+// HandleReleases lists releases for operators; it responds with 404 if no operator token is configured.
+func (h *Handler) HandleReleases(w http.ResponseWriter, r *http.Request) {
+	if h.releases == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.releases.Handle(w, r)
+}
+
+// HandleDevices lists devices for operators; it responds with 404 if no operator token is configured.
+func (h *Handler) HandleDevices(w http.ResponseWriter, r *http.Request) {
+	if h.devices == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.devices.Handle(w, r)
+}
+
+// HandleCredentials manages device credentials for operators; it responds with 404 if no operator token is configured
+// or device authentication is off.
+func (h *Handler) HandleCredentials(w http.ResponseWriter, r *http.Request) {
+	if h.creds == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.creds.Handle(w, r)
+}
+
+// HandleHalts shows and lifts halts of release rollouts for operators; it responds with 404 if no operator token is
+// configured.
+func (h *Handler) HandleHalts(w http.ResponseWriter, r *http.Request) {
+	if h.halts == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.halts.Handle(w, r)
+}
@@ -0,0 +1,130 @@
+package hook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/update"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+const (
+	maxBodySize    = 5 << 20
+	refreshTimeout = 5 * time.Minute
+)
+
+type releaseEvent struct {
+	Action     string `json:"action"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// GitHubHandler receives GitHub webhooks and refreshes releases of apps on release events.
+type GitHubHandler struct {
+	updSvc *update.Service
+	secret []byte
+	l      zerolog.Logger
+}
+
+func NewGitHub(updSvc *update.Service, secret string, l zerolog.Logger) *GitHubHandler {
+	return &GitHubHandler{
+		updSvc: updSvc,
+		secret: []byte(secret),
+		l:      l,
+	}
+}
+
+func (h *GitHubHandler) Handle(rw http.ResponseWriter, req *http.Request) {
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, "/v2/hooks/github")
+
+	if req.Method != http.MethodPost {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("github hook request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		m(http.StatusBadRequest)
+		l.Warn().Err(fmt.Errorf("read request: %w", err)).Msg("github hook request failed")
+		rpcutil.WriteBadRequest(rw, "invalid request", l)
+		return
+	}
+
+	if !h.validSignature(b, req.Header.Get("X-Hub-Signature-256")) {
+		m(http.StatusUnauthorized)
+		l.Warn().Err(errors.New("invalid signature")).Msg("github hook request failed")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	event := req.Header.Get("X-GitHub-Event")
+	l.Info().Str("event", event).Str("delivery", req.Header.Get("X-GitHub-Delivery")).Msg("github hook request")
+
+	if event != "release" {
+		m(http.StatusNoContent)
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ev := releaseEvent{}
+	if err := json.Unmarshal(b, &ev); err != nil {
+		m(http.StatusBadRequest)
+		l.Warn().Err(fmt.Errorf("unmarshal request: %w", err)).Msg("github hook request failed")
+		rpcutil.WriteBadRequest(rw, "invalid request", l)
+		return
+	}
+
+	owner, name := ev.Repository.Owner.Login, ev.Repository.Name
+	if owner == "" || name == "" {
+		m(http.StatusBadRequest)
+		l.Warn().Err(errors.New("no repository")).Msg("github hook request failed")
+		rpcutil.WriteBadRequest(rw, "no repository", l)
+		return
+	}
+
+	// GitHub expects a response within seconds, so releases are refreshed in background
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), refreshTimeout)
+		defer cancel()
+
+		if err := h.updSvc.Refresh(ctx, owner, name); err != nil {
+			l.Error().Err(err).Str("repo", owner+"/"+name).Msg("failed to refresh releases")
+			return
+		}
+
+		l.Info().Str("repo", owner+"/"+name).Str("action", ev.Action).Msg("releases refreshed")
+	}()
+
+	m(http.StatusAccepted)
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+func (h *GitHubHandler) validSignature(body []byte, hdr string) bool {
+	sig, err := hex.DecodeString(strings.TrimPrefix(hdr, "sha256="))
+	if err != nil || !strings.HasPrefix(hdr, "sha256=") {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+
+	return hmac.Equal(sig, mac.Sum(nil))
+}
@@ -0,0 +1,215 @@
+package releases
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/ashep/d5y/internal/update"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+type Exclusion struct {
+	Versions string `json:"versions"`
+	Reason   string `json:"reason"`
+}
+
+type Revocation struct {
+	RollbackTo string `json:"rollback_to,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+type Asset struct {
+	update.Asset
+	ChecksumStatus string `json:"checksum_status"` // ok, sha512_only or missing
+}
+
+type Release struct {
+	update.Release
+	Assets       []Asset     `json:"assets"`
+	ExcludedFrom *Exclusion  `json:"excluded_from,omitempty"`
+	ExcludedTo   *Exclusion  `json:"excluded_to,omitempty"`
+	Revoked      *Revocation `json:"revoked,omitempty"`
+}
+
+type Response struct {
+	Owner    string         `json:"owner"`
+	Name     string         `json:"name"`
+	Arch     string         `json:"arch"`
+	Channel  update.Channel `json:"channel"`
+	Releases []Release      `json:"releases"`
+	Path     []string       `json:"path,omitempty"` // versions a device goes through from the `from` version
+}
+
+// Handler lists releases of an app as the update service resolves them, for operators.
+type Handler struct {
+	updSvc *update.Service
+	l      zerolog.Logger
+}
+
+// New creates the handler. Requests must be authenticated by operator.WrapHTTP.
+func New(updSvc *update.Service, l zerolog.Logger) *Handler {
+	return &Handler{
+		updSvc: updSvc,
+		l:      l,
+	}
+}
+
+// Handle responds with the release set of the `app=owner:name:arch` app.
+//
+// Optional query parameters: `channel`, nightly by default; `from`, the version to compute the upgrade path from;
+// `device` and `country`, the device ID and the country code the path is computed for.
+func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) { //nolint:cyclop // later
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, "/v2/firmware/releases")
+
+	if req.Method != http.MethodGet {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("firmware releases request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := req.URL.Query()
+	l.Info().Str("query", q.Encode()).Msg("firmware releases request")
+
+	// `{owner}:{name}:{arch}` or `{alias}:{arch}`
+	appS := strings.Split(q.Get("app"), ":")
+	if len(appS) == 2 {
+		appS = append([]string{""}, appS...)
+	} else if len(appS) != 3 {
+		m(http.StatusBadRequest)
+		l.Warn().Err(errors.New("invalid app")).Msg("firmware releases request failed")
+		rpcutil.WriteBadRequest(rw, "invalid app", l)
+		return
+	}
+
+	appRef := appS[1]
+	if appS[0] != "" {
+		appRef = appS[0] + "/" + appS[1]
+	}
+
+	ch := update.ChannelNightly
+	if chQ := q.Get("channel"); chQ != "" {
+		var err error
+		if ch, err = update.ParseChannel(chQ); err != nil {
+			m(http.StatusBadRequest)
+			l.Warn().Err(err).Msg("firmware releases request failed")
+			rpcutil.WriteBadRequest(rw, "invalid channel", l)
+			return
+		}
+	}
+
+	var from *semver.Version
+	if fromQ := q.Get("from"); fromQ != "" {
+		var err error
+		if from, err = semver.NewVersion(fromQ); err != nil {
+			m(http.StatusBadRequest)
+			l.Warn().Err(errors.New("invalid from version")).Msg("firmware releases request failed")
+			rpcutil.WriteBadRequest(rw, "invalid from version", l)
+			return
+		}
+	}
+
+	owner, name, err := h.updSvc.ResolveApp(appRef, appS[2])
+	if errors.Is(err, update.ErrAppNotFound) || errors.Is(err, update.ErrArchNotSupported) {
+		m(http.StatusNotFound)
+		l.Warn().Err(err).Msg("firmware releases request failed")
+		rpcutil.WriteNotFound(rw, err.Error(), l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	rlsSet, err := h.updSvc.List(req.Context(), owner, name, appS[2], ch)
+	if errors.Is(err, update.ErrAppNotFound) {
+		m(http.StatusNotFound)
+		l.Warn().Err(err).Msg("firmware releases request failed")
+		rpcutil.WriteNotFound(rw, err.Error(), l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	rsp := Response{
+		Owner:    owner,
+		Name:     name,
+		Arch:     appS[2],
+		Channel:  ch,
+		Releases: make([]Release, 0, len(rlsSet.List)),
+	}
+
+	for _, rls := range rlsSet.List {
+		rsp.Releases = append(rsp.Releases, newRelease(rlsSet, rls))
+	}
+
+	if from != nil {
+		dev := clientinfo.Info{ID: q.Get("device"), CountryCode: q.Get("country"), Hardware: appS[2]}
+
+		rsp.Path = make([]string, 0)
+		for _, rls := range rlsSet.Path(from, dev) {
+			rsp.Path = append(rsp.Path, rls.Version.String())
+		}
+	}
+
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, fmt.Errorf("marshal response: %w", err), l)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(b); err != nil {
+		m(http.StatusInternalServerError)
+		l.Error().Err(fmt.Errorf("write response: %w", err)).Msg("firmware releases request failed")
+		return
+	}
+
+	m(http.StatusOK)
+}
+
+func newRelease(rlsSet *update.ReleaseSet, rls update.Release) Release {
+	res := Release{
+		Release: rls,
+		Assets:  make([]Asset, 0, len(rls.Assets)),
+	}
+
+	for _, ast := range rls.Assets {
+		status := "missing"
+		switch {
+		case ast.SHA256 != "":
+			status = "ok"
+		case ast.SHA512 != "":
+			status = "sha512_only"
+		}
+
+		res.Assets = append(res.Assets, Asset{Asset: ast, ChecksumStatus: status})
+	}
+
+	if exc, ok := rlsSet.ExcludedFrom(rls.Version); ok {
+		res.ExcludedFrom = &Exclusion{Versions: exc.Versions, Reason: exc.Reason}
+	}
+
+	if exc, ok := rlsSet.ExcludedTo(rls.Version); ok {
+		res.ExcludedTo = &Exclusion{Versions: exc.Versions, Reason: exc.Reason}
+	}
+
+	if rev, ok := rlsSet.Revoked(rls.Version); ok {
+		res.Revoked = &Revocation{RollbackTo: rev.RollbackTo, Reason: rev.Reason}
+	}
+
+	return res
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/ashep/d5y/internal/inventory"
+	"github.com/ashep/d5y/internal/report"
+	"github.com/ashep/d5y/internal/update"
+	"github.com/ashep/go-app/metrics"
+	"github.com/rs/zerolog"
+)
+
+const maxBodySize = 4096
+
+type Request struct {
+	App     string       `json:"app"`     // `{owner}:{name}` or `{alias}`
+	Version string       `json:"version"` // version being installed
+	Stage   report.Stage `json:"stage"`
+	Code    int          `json:"code"`
+}
+
+type Handler struct {
+	reportSvc *report.Service
+	updSvc    *update.Service
+	inv       *inventory.Registry
+	l         zerolog.Logger
+}
+
+func New(reportSvc *report.Service, updSvc *update.Service, inv *inventory.Registry, l zerolog.Logger) *Handler {
+	return &Handler{
+		reportSvc: reportSvc,
+		updSvc:    updSvc,
+		inv:       inv,
+		l:         l,
+	}
+}
+
+func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) {
+	l := rpcutil.ReqLog(req, h.l)
+	m := metrics.HTTPServerRequest(req, "/v2/firmware/report")
+
+	if req.Method != http.MethodPost {
+		m(http.StatusMethodNotAllowed)
+		l.Warn().Err(errors.New("method not allowed")).Msg("firmware report request failed")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		m(http.StatusBadRequest)
+		l.Warn().Err(fmt.Errorf("read request: %w", err)).Msg("firmware report request failed")
+		rpcutil.WriteBadRequest(rw, "invalid request", l)
+		return
+	}
+
+	reqData := Request{}
+	if err := json.Unmarshal(b, &reqData); err != nil {
+		m(http.StatusBadRequest)
+		l.Warn().Err(fmt.Errorf("unmarshal request: %w", err)).Msg("firmware report request failed")
+		rpcutil.WriteBadRequest(rw, "invalid request", l)
+		return
+	}
+
+	l.Info().
+		Str("app", reqData.App).
+		Str("version", reqData.Version).
+		Str("stage", string(reqData.Stage)).
+		Int("code", reqData.Code).
+		Msg("firmware report request")
+
+	// Only reports about served releases are accepted, so arbitrary apps and versions are not stored
+	owner, name, err := h.updSvc.ResolveApp(strings.Replace(reqData.App, ":", "/", 1), "")
+	if errors.Is(err, update.ErrAppNotFound) {
+		m(http.StatusNotFound)
+		l.Warn().Err(err).Msg("firmware report request failed")
+		rpcutil.WriteNotFound(rw, err.Error(), l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	ver, err := semver.NewVersion(reqData.Version)
+	if err != nil {
+		m(http.StatusBadRequest)
+		l.Warn().Err(errors.New("invalid version")).Msg("firmware report request failed")
+		rpcutil.WriteBadRequest(rw, "invalid version", l)
+		return
+	}
+
+	released, err := h.updSvc.Released(req.Context(), owner, name, ver)
+	if err != nil && !errors.Is(err, update.ErrAppNotFound) {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	if !released {
+		m(http.StatusNotFound)
+		l.Warn().Err(errors.New("unknown release")).Msg("firmware report request failed")
+		rpcutil.WriteNotFound(rw, "unknown release", l)
+		return
+	}
+
+	ci := clientinfo.FromCtx(req.Context())
+
+	err = h.reportSvc.Add(req.Context(), report.Report{
+		DeviceID: ci.ID,
+		Owner:    owner,
+		Name:     name,
+		Version:  reqData.Version,
+		Stage:    reqData.Stage,
+		Code:     reqData.Code,
+		Verified: ci.Authenticated || h.inv.Known(ci.ID),
+	})
+	if errors.Is(err, report.ErrInvalidReport) {
+		m(http.StatusBadRequest)
+		l.Warn().Err(err).Msg("firmware report request failed")
+		rpcutil.WriteBadRequest(rw, err.Error(), l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	m(http.StatusNoContent)
+	rw.WriteHeader(http.StatusNoContent)
+}
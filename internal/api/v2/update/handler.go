@@ -5,24 +5,33 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/ashep/d5y/internal/signature"
 	"github.com/ashep/d5y/internal/update"
 	"github.com/ashep/go-app/metrics"
 	"github.com/rs/zerolog"
 )
 
 type Handler struct {
-	updSvc *update.Service
-	l      zerolog.Logger
+	updSvc   *update.Service
+	signer   *signature.Signer
+	offerTTL time.Duration
+	l        zerolog.Logger
 }
 
-func New(updSvc *update.Service, l zerolog.Logger) *Handler {
+// New creates a new firmware update handler. Offers are not signed if signer is nil.
+func New(updSvc *update.Service, signer *signature.Signer, offerTTL time.Duration, l zerolog.Logger) *Handler {
 	return &Handler{
-		updSvc: updSvc,
-		l:      l,
+		updSvc:   updSvc,
+		signer:   signer,
+		offerTTL: offerTTL,
+		l:        l,
 	}
 }
 
@@ -48,14 +57,48 @@ func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) { //nolint:c
 		return
 	}
 
+	// `{owner}:{name}:{arch}:{version}` or `{alias}:{arch}:{version}`
 	appS := strings.Split(appQ, ":")
-	if len(appS) != 4 {
+	if len(appS) == 3 {
+		appS = append([]string{""}, appS...)
+	} else if len(appS) != 4 {
 		m(http.StatusBadRequest)
 		l.Warn().Err(errors.New("invalid app")).Msg("firmware update request failed")
 		rpcutil.WriteBadRequest(rw, "invalid app", l)
 		return
 	}
 
+	appRef := appS[1]
+	if appS[0] != "" {
+		appRef = appS[0] + "/" + appS[1]
+	}
+
+	ci := clientinfo.FromCtx(req.Context())
+
+	hw := appS[2]
+	if hw == "" {
+		hw = ci.Hardware
+	}
+
+	owner, name, err := h.updSvc.ResolveApp(appRef, hw)
+	if errors.Is(err, update.ErrAppNotFound) || errors.Is(err, update.ErrArchNotSupported) {
+		m(http.StatusNotFound)
+		l.Warn().Err(err).Str("app", appRef).Str("arch", hw).Msg("firmware update request failed")
+		rpcutil.WriteNotFound(rw, err.Error(), l)
+		return
+	} else if err != nil {
+		m(http.StatusInternalServerError)
+		rpcutil.WriteInternalServerError(rw, err, l)
+		return
+	}
+
+	if !ci.Authenticated && h.updSvc.AuthRequired(owner, name) {
+		m(http.StatusUnauthorized)
+		l.Warn().Err(errors.New("device not authenticated")).Str("app", appRef).Msg("firmware update request failed")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
 	ver, err := semver.NewVersion(appS[3])
 	if err != nil {
 		m(http.StatusBadRequest)
@@ -64,7 +107,41 @@ func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) { //nolint:c
 		return
 	}
 
-	rlsSet, err := h.updSvc.List(req.Context(), appS[0], appS[1], appS[2], q.Get("to_alpha") == "1")
+	rspVer := q.Get("response_version")
+	if rspVer != "" && rspVer != "1" && rspVer != "2" {
+		m(http.StatusBadRequest)
+		l.Warn().Err(errors.New("invalid response version")).Msg("firmware update request failed")
+		rpcutil.WriteBadRequest(rw, "invalid response version", l)
+		return
+	}
+
+	notesLimit := 0
+	if v := q.Get("notes_limit"); v != "" {
+		if notesLimit, err = strconv.Atoi(v); err != nil || notesLimit < 0 {
+			m(http.StatusBadRequest)
+			l.Warn().Err(errors.New("invalid notes limit")).Msg("firmware update request failed")
+			rpcutil.WriteBadRequest(rw, "invalid notes limit", l)
+			return
+		}
+	}
+
+	ch := update.ChannelStable
+	if q.Get("to_alpha") == "1" { // BC
+		ch = update.ChannelAlpha
+	}
+
+	if chQ := q.Get("channel"); chQ != "" {
+		if ch, err = update.ParseChannel(chQ); err != nil {
+			m(http.StatusBadRequest)
+			l.Warn().Err(err).Msg("firmware update request failed")
+			rpcutil.WriteBadRequest(rw, "invalid channel", l)
+			return
+		}
+	}
+
+	ch = h.updSvc.Channel(owner, name, ci.ID, ch)
+
+	rlsSet, err := h.updSvc.List(req.Context(), owner, name, hw, ch)
 	if errors.Is(err, update.ErrAppNotFound) {
 		m(http.StatusNotFound)
 		l.Warn().Err(errors.New("unknown client app")).Msg("firmware update request failed")
@@ -76,7 +153,34 @@ func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) { //nolint:c
 		return
 	}
 
-	rls := rlsSet.Next(ver)
+	rls, rev := rlsSet.Rollback(ver)
+	if rls != nil {
+		l.Warn().
+			Str("rollback_to", rls.Version.String()).
+			Str("reason", rev.Reason).
+			Msg("current version is revoked")
+	} else if rev.Reason != "" {
+		l.Error().
+			Str("rollback_to", rev.RollbackTo).
+			Str("reason", rev.Reason).
+			Msg("current version is revoked, but no rollback release is available")
+	}
+
+	if exc, ok := rlsSet.ExcludedFrom(ver); ok && rls == nil {
+		m(http.StatusOK) // OK is the correct code here
+		l.Info().
+			Str("result", "current version is excluded from updates").
+			Str("reason", exc.Reason).
+			Msg("firmware update response")
+		rpcutil.WriteNotFound(rw, "no firmware update found: "+exc.Reason, l)
+		return
+	}
+
+	downgrade := rls != nil
+	if rls == nil {
+		rls = rlsSet.Next(ver, ci)
+	}
+
 	if rls == nil {
 		m(http.StatusOK) // OK is the correct code here
 		l.Info().Str("result", "no next release").Msg("firmware update response")
@@ -94,7 +198,31 @@ func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) { //nolint:c
 		return
 	}
 
-	b, err := json.Marshal(rls.Assets[0])
+	// Rollbacks from revoked releases are urgent, so they are not postponed
+	if wait := rlsSet.WindowWait(rls, ci, time.Now()); wait > 0 && !downgrade {
+		retryAfter := int(wait.Round(time.Second).Seconds())
+		m(http.StatusOK) // OK is the correct code here
+		l.Info().
+			Str("release", rls.Version.String()).
+			Str("result", "outside of the update window").
+			Int("retry_after", retryAfter).
+			Msg("firmware update response")
+		rw.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		rpcutil.WriteNotFound(rw, "no firmware update found: outside of the update window", l)
+		return
+	}
+
+	delta := rlsSet.Delta(rls, ver)
+
+	var rsp any = newResponse(rls, ver, delta, downgrade, h.signer, h.offerTTL)
+	if rspVer == "2" {
+		rspV2 := newResponseV2(rls, ver, delta, downgrade, h.signer, h.offerTTL)
+		notes := rls.NotesFor(parseAcceptLanguage(req.Header.Get("Accept-Language")))
+		rspV2.Notes, rspV2.NotesTruncated = truncateNotes(notes, notesLimit)
+		rsp = rspV2
+	}
+
+	b, err := json.Marshal(rsp)
 	if err != nil {
 		m(http.StatusInternalServerError)
 		l.Error().Err(fmt.Errorf("marshal response: %w", err)).Msg("firmware update request failed")
@@ -113,7 +241,11 @@ func (h *Handler) Handle(rw http.ResponseWriter, req *http.Request) { //nolint:c
 	m(http.StatusOK)
 
 	l.Info().
-		Str("download_url", rls.Assets[0].URL).
+		Str("download_url", rls.AppAsset().URL).
+		Int("assets", len(rls.Assets)).
 		Str("version", rls.Version.String()).
+		Str("channel", string(ch)).
+		Bool("downgrade", downgrade).
+		Bool("delta", delta != nil).
 		Msg("firmware update response")
 }
@@ -0,0 +1,68 @@
+package update
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseAcceptLanguage returns language tags of the `Accept-Language` header value in the order of preference.
+func parseAcceptLanguage(s string) []string {
+	type langQ struct {
+		tag string
+		q   float64
+	}
+
+	langs := make([]langQ, 0)
+
+	for _, part := range strings.Split(s, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > 0 {
+			langs = append(langs, langQ{tag: tag, q: q})
+		}
+	}
+
+	slices.SortStableFunc(langs, func(a, b langQ) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	res := make([]string, len(langs))
+	for i, l := range langs {
+		res[i] = l.tag
+	}
+
+	return res
+}
+
+// truncateNotes cuts s to at most limit bytes without splitting UTF-8 characters. Zero limit means no limit.
+func truncateNotes(s string, limit int) (string, bool) {
+	if limit <= 0 || len(s) <= limit {
+		return s, false
+	}
+
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+
+	return s[:limit], true
+}
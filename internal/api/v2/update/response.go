@@ -0,0 +1,160 @@
+package update
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/signature"
+	"github.com/ashep/d5y/internal/update"
+)
+
+// Response is the single asset response, returned unless a client opts into another response version.
+type Response struct {
+	Name      string        `json:"name"`
+	Size      int           `json:"size"`
+	SHA256    string        `json:"sha256"`
+	URL       string        `json:"url"`
+	Downgrade bool          `json:"downgrade,omitempty"` // the device must allow installing an older version
+	Delta     *update.Delta `json:"delta,omitempty"`     // patch of the currently installed app image, if available
+	Signature *Signature    `json:"signature,omitempty"`
+}
+
+// ResponseV2 describes all the release assets matching the client.
+type ResponseV2 struct {
+	Version        string         `json:"version"`
+	Title          string         `json:"title,omitempty"`
+	Notes          string         `json:"notes,omitempty"`           // in the preferred language, if available
+	NotesTruncated bool           `json:"notes_truncated,omitempty"` // notes are cut to the requested limit
+	PublishedAt    time.Time      `json:"published_at,omitzero"`
+	Critical       bool           `json:"critical,omitempty"`  // the release fixes a critical or security issue
+	Downgrade      bool           `json:"downgrade,omitempty"` // the device must allow installing an older version
+	Assets         []update.Asset `json:"assets"`
+	Delta          *update.Delta  `json:"delta,omitempty"` // patch of the currently installed app image, if available
+	Signature      *Signature     `json:"signature,omitempty"`
+}
+
+// Signature is an Ed25519 signature of an update offer.
+//
+// The signed message is a sequence of netstrings, `{byte length}:{value},`, so values may contain any characters:
+// `d5y-offer-v2`, the version, the version the device runs, the expiration Unix time, the `downgrade` and `critical`
+// flags as `true` or `false`, the number of offered assets followed by the name, size, sha256 and url of every asset in
+// the order of the response, and the number of deltas, 0 or 1, followed by the name, size, sha256, url, base_sha256 and
+// result_sha256 of the delta. Numbers are decimal. Devices must check that the signed current version is their own
+// one, and must take the flags from the signature.
+type Signature struct {
+	KeyID     string `json:"key_id"`
+	Version   string `json:"version"`
+	From      string `json:"from"` // the version the device runs
+	Expires   int64  `json:"expires"`
+	Downgrade bool   `json:"downgrade"`
+	Critical  bool   `json:"critical"`
+	Value     string `json:"value"` // base64 encoded
+}
+
+func newResponse(
+	rls *update.Release,
+	current *semver.Version,
+	delta *update.Delta,
+	downgrade bool,
+	sig *signature.Signer,
+	ttl time.Duration,
+) Response {
+	ast := rls.AppAsset()
+
+	return Response{
+		Name:      ast.Name,
+		Size:      ast.Size,
+		SHA256:    ast.SHA256,
+		URL:       ast.URL,
+		Downgrade: downgrade,
+		Delta:     delta,
+		Signature: signOffer(rls, current, []update.Asset{*ast}, delta, downgrade, sig, ttl),
+	}
+}
+
+func newResponseV2(
+	rls *update.Release,
+	current *semver.Version,
+	delta *update.Delta,
+	downgrade bool,
+	sig *signature.Signer,
+	ttl time.Duration,
+) ResponseV2 {
+	return ResponseV2{
+		Version:     rls.Version.String(),
+		Title:       rls.Title,
+		Notes:       rls.Notes,
+		PublishedAt: rls.PublishedAt,
+		Critical:    rls.Critical,
+		Downgrade:   downgrade,
+		Assets:      rls.Assets,
+		Delta:       delta,
+		Signature:   signOffer(rls, current, rls.Assets, delta, downgrade, sig, ttl),
+	}
+}
+
+func signOffer(
+	rls *update.Release,
+	current *semver.Version,
+	assets []update.Asset,
+	delta *update.Delta,
+	downgrade bool,
+	sig *signature.Signer,
+	ttl time.Duration,
+) *Signature {
+	if sig == nil {
+		return nil
+	}
+
+	res := &Signature{
+		KeyID:     sig.KeyID(),
+		Version:   rls.Version.String(),
+		From:      current.String(),
+		Expires:   time.Now().Add(ttl).Unix(),
+		Downgrade: downgrade,
+		Critical:  rls.Critical,
+	}
+
+	res.Value = sig.Sign(offerMessage(res, assets, delta))
+
+	return res
+}
+
+// offerMessage returns the signed message of the offer.
+func offerMessage(sig *Signature, assets []update.Asset, delta *update.Delta) []byte {
+	msg := strings.Builder{}
+	field := func(v string) {
+		msg.WriteString(strconv.Itoa(len(v)) + ":" + v + ",")
+	}
+
+	field("d5y-offer-v2")
+	field(sig.Version)
+	field(sig.From)
+	field(strconv.FormatInt(sig.Expires, 10))
+	field(strconv.FormatBool(sig.Downgrade))
+	field(strconv.FormatBool(sig.Critical))
+
+	field(strconv.Itoa(len(assets)))
+	for _, ast := range assets {
+		field(ast.Name)
+		field(strconv.Itoa(ast.Size))
+		field(ast.SHA256)
+		field(ast.URL)
+	}
+
+	if delta == nil {
+		field("0")
+	} else {
+		field("1")
+		field(delta.Name)
+		field(strconv.Itoa(delta.Size))
+		field(delta.SHA256)
+		field(delta.URL)
+		field(delta.BaseSHA256)
+		field(delta.ResultSHA256)
+	}
+
+	return []byte(msg.String())
+}
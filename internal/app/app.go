@@ -2,12 +2,24 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"time"
 
 	handlerNotFound "github.com/ashep/d5y/internal/api/notfound"
+	"github.com/ashep/d5y/internal/api/operator"
 	handlerV1 "github.com/ashep/d5y/internal/api/v1"
 	handlerV2 "github.com/ashep/d5y/internal/api/v2"
 	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/ashep/d5y/internal/devauth"
+	"github.com/ashep/d5y/internal/ghapp"
+	"github.com/ashep/d5y/internal/inventory"
+	"github.com/ashep/d5y/internal/mirror"
+	"github.com/ashep/d5y/internal/report"
+	"github.com/ashep/d5y/internal/signature"
+	"github.com/ashep/d5y/internal/signedurl"
 	"github.com/ashep/d5y/internal/update"
 	"github.com/ashep/d5y/internal/weatherapi"
 	"github.com/ashep/go-app/runner"
@@ -15,44 +27,298 @@ import (
 	"github.com/rs/zerolog"
 )
 
+const (
+	defaultOfferTTL = time.Hour
+	defaultLinkTTL  = 15 * time.Minute
+)
+
 type App struct {
-	rt *runner.Runtime
-	l  zerolog.Logger
+	rt     *runner.Runtime
+	updSvc *update.Service
+	inv    *inventory.Registry
+	l      zerolog.Logger
 }
 
 func New(cfg *Config, rt *runner.Runtime) (*App, error) {
 	l := rt.Logger
 
 	weatherSvc := weatherapi.New(cfg.Weather.APIKey)
-	githubCli := github.NewClient(http.DefaultClient).WithAuthToken(cfg.GitHub.Token)
-	updSvc := update.New(githubCli, l.With().Str("pkg", "update_svc").Logger())
+	githubCli, err := newGitHubClient(cfg.GitHub)
+	if err != nil {
+		return nil, fmt.Errorf("github client: %w", err)
+	}
+
+	updSrc, updAppSrc, err := newUpdateSources(cfg.Update, githubCli, l.With().Str("pkg", "github_source").Logger())
+	if err != nil {
+		return nil, fmt.Errorf("update sources: %w", err)
+	}
+
+	var reportStore report.Store = report.NewMemoryStore()
+	if cfg.Report.File != "" {
+		if reportStore, err = report.NewFileStore(cfg.Report.File); err != nil {
+			return nil, fmt.Errorf("report store: %w", err)
+		}
+	}
+
+	reportSvc := report.New(reportStore, report.Config{
+		FailureThreshold: cfg.Report.FailureThreshold,
+		MinReports:       cfg.Report.MinReports,
+	}, l.With().Str("pkg", "report_svc").Logger())
+
+	var invStore inventory.Store = inventory.NewMemoryStore()
+	if cfg.Inventory.File != "" {
+		if invStore, err = inventory.NewFileStore(cfg.Inventory.File); err != nil {
+			return nil, fmt.Errorf("inventory store: %w", err)
+		}
+	}
+
+	inv, err := inventory.New(context.Background(), invStore, inventory.Config{
+		MaxDevices:        cfg.Inventory.MaxDevices,
+		AuthenticatedOnly: cfg.Inventory.AuthenticatedOnly,
+	}, l.With().Str("pkg", "inventory").Logger())
+	if err != nil {
+		return nil, fmt.Errorf("inventory: %w", err)
+	}
+
+	var auth *devauth.Service
+	if cfg.Auth.Secret != "" {
+		var authStore devauth.Store = devauth.NewMemoryStore()
+		if cfg.Auth.File != "" {
+			if authStore, err = devauth.NewFileStore(cfg.Auth.File); err != nil {
+				return nil, fmt.Errorf("auth store: %w", err)
+			}
+		}
+
+		if auth, err = devauth.New(cfg.Auth.Secret, authStore); err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	} else if cfg.Auth.Strict || cfg.Auth.Reports || cfg.Auth.PrivateApps || cfg.Inventory.AuthenticatedOnly {
+		return nil, errors.New("auth: empty secret")
+	}
+
+	var signer *signature.Signer
+	if cfg.Signing.Key != "" {
+		if signer, err = signature.NewSigner(cfg.Signing.KeyID, cfg.Signing.Key); err != nil {
+			return nil, fmt.Errorf("signer: %w", err)
+		}
+		l.Info().Str("key_id", signer.KeyID()).Str("public_key", signer.PublicKey()).Msg("update offers are signed")
+	}
+
+	offerTTL := cfg.Signing.OfferTTL
+	if offerTTL <= 0 {
+		offerTTL = defaultOfferTTL
+	}
+
+	updCfg := update.Config{
+		RefreshInterval: cfg.Update.RefreshInterval,
+		MaxAge:          cfg.Update.MaxAge,
+		PolicyFile:      cfg.Update.PolicyFile,
+		Halter:          reportSvc,
+		StrictChecksums: cfg.Update.StrictChecksums,
+		AllowAllApps:    cfg.Update.AllowAllApps,
+		PrivateAuth:     cfg.Auth.PrivateApps,
+	}
+
+	if len(cfg.Signing.PublisherKeys) != 0 {
+		if updCfg.Verifier, err = signature.NewVerifier(cfg.Signing.PublisherKeys); err != nil {
+			return nil, fmt.Errorf("verifier: %w", err)
+		}
+	}
+
+	var mrr *mirror.Mirror
+	if cfg.Mirror.Dir != "" {
+		if cfg.Mirror.URL == "" {
+			return nil, errors.New("mirror: empty url")
+		}
+
+		mrr, err = mirror.New(cfg.Mirror.Dir, cfg.Mirror.URL, cfg.Mirror.MaxSize, l.With().Str("pkg", "mirror").Logger())
+		if err != nil {
+			return nil, fmt.Errorf("mirror: %w", err)
+		}
+
+		updCfg.Mirror = mrr
+	}
+
+	var linker *signedurl.Signer
+	if cfg.Link.Secret != "" {
+		linkTTL := cfg.Link.TTL
+		if linkTTL <= 0 {
+			linkTTL = defaultLinkTTL
+		}
+
+		if linker, err = signedurl.New(cfg.Link.Secret, cfg.Link.URL, linkTTL); err != nil {
+			return nil, fmt.Errorf("linker: %w", err)
+		}
+
+		updCfg.Linker = linker
+		updCfg.PrivateApps = cfg.Link.PrivateApps
+	}
+
+	updSvc, err := update.New(updSrc, updAppSrc, updCfg, l.With().Str("pkg", "update_svc").Logger())
+	if err != nil {
+		return nil, fmt.Errorf("update service: %w", err)
+	}
+
+	wrapDevice := func(h http.HandlerFunc, l zerolog.Logger) http.HandlerFunc {
+		return wrapDeviceMiddlewares(h, inv, auth, cfg.Auth.Strict, l)
+	}
 
 	logV1 := l.With().Str("pkg", "v1_handler").Logger()
 	hdlV1 := handlerV1.New(weatherSvc, logV1)
-	rt.Server.HandleFunc("/api/1", wrapMiddlewares(hdlV1.Handle, logV1)) // BC
+	rt.Server.HandleFunc("/api/1", wrapDevice(hdlV1.Handle, logV1)) // BC
 
 	logV2 := l.With().Str("pkg", "v2_handler").Logger()
-	hdlV2 := handlerV2.New(weatherSvc, updSvc, logV2)
-	rt.Server.Handle("/v2/time", wrapMiddlewares(hdlV2.HandleTime, logV2))
-	rt.Server.Handle("/v2/weather", wrapMiddlewares(hdlV2.HandleWeather, logV2))
-	rt.Server.Handle("/v2/firmware/update", wrapMiddlewares(hdlV2.HandleUpdate, logV2))
+	hdlV2 := handlerV2.New(
+		weatherSvc,
+		updSvc,
+		reportSvc,
+		inv,
+		auth,
+		mrr,
+		linker,
+		signer,
+		offerTTL,
+		cfg.GitHub.WebhookSecret,
+		cfg.Operator.Token,
+		logV2,
+	)
+
+	hdlReport := hdlV2.HandleReport
+	if cfg.Auth.Reports {
+		hdlReport = devauth.Require(hdlReport, logV2)
+	}
+
+	rt.Server.Handle("/v2/time", wrapDevice(hdlV2.HandleTime, logV2))
+	rt.Server.Handle("/v2/weather", wrapDevice(hdlV2.HandleWeather, logV2))
+	rt.Server.Handle("/v2/firmware/update", wrapDevice(hdlV2.HandleUpdate, logV2))
+	rt.Server.Handle("/v2/firmware/report", wrapDevice(hdlReport, logV2))
+	rt.Server.Handle("/v2/firmware/download/", wrapDevice(hdlV2.HandleDownload, logV2))
+	rt.Server.Handle("/v2/firmware/releases", operator.WrapHTTP(hdlV2.HandleReleases, cfg.Operator.Token, logV2))
+	rt.Server.Handle("/v2/firmware/halts", operator.WrapHTTP(hdlV2.HandleHalts, cfg.Operator.Token, logV2))
+	rt.Server.Handle("/v2/firmware/asset", wrapDevice(hdlV2.HandleAsset, logV2))
+	rt.Server.Handle("/v2/devices", operator.WrapHTTP(hdlV2.HandleDevices, cfg.Operator.Token, logV2))
+	rt.Server.Handle("/v2/devices/credentials", operator.WrapHTTP(hdlV2.HandleCredentials, cfg.Operator.Token, logV2))
+	rt.Server.Handle("/v2/hooks/github", wrapMiddlewares(hdlV2.HandleGitHubHook, logV2))
 
 	log404 := l.With().Str("pkg", "404_handler").Logger()
 	hdl404 := handlerNotFound.New(log404)
 	rt.Server.HandleFunc("/", wrapMiddlewares(hdl404.Handle, log404))
 
 	return &App{
-		rt: rt,
-		l:  l,
+		rt:     rt,
+		updSvc: updSvc,
+		inv:    inv,
+		l:      l,
 	}, nil
 }
 
 func (a *App) Run(ctx context.Context) error {
+	go a.updSvc.Run(ctx)
+
+	// The inventory saves remaining devices on stop, so the server waits for it
+	invCtx, invCancel := context.WithCancel(ctx)
+	invDone := make(chan struct{})
+	go func() {
+		a.inv.Run(invCtx)
+		close(invDone)
+	}()
+
 	a.l.Info().Str("addr", a.rt.Server.Listener().Addr().String()).Msg("starting server")
-	return <-a.rt.Server.Start(ctx)
+	err := <-a.rt.Server.Start(ctx)
+
+	invCancel()
+	<-invDone
+
+	return err
 }
 
 func wrapMiddlewares(h http.HandlerFunc, l zerolog.Logger) http.HandlerFunc {
 	h = clientinfo.WrapHTTP(h, l)
 	return h
 }
+
+// wrapDeviceMiddlewares wraps handlers of device requests, which are authenticated, if auth is set,
+// and recorded to the inventory.
+func wrapDeviceMiddlewares(
+	h http.HandlerFunc,
+	inv *inventory.Registry,
+	auth *devauth.Service,
+	strictAuth bool,
+	l zerolog.Logger,
+) http.HandlerFunc {
+	h = inventory.WrapHTTP(h, inv)
+	h = clientinfo.WrapHTTP(h, l)
+	if auth != nil {
+		h = devauth.WrapHTTP(h, auth, strictAuth, l)
+	}
+	return h
+}
+
+func newGitHubClient(cfg GitHubConfig) (*github.Client, error) {
+	if cfg.AppID == 0 {
+		return github.NewClient(http.DefaultClient).WithAuthToken(cfg.Token), nil
+	}
+
+	key, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+
+	tr, err := ghapp.New(cfg.AppID, cfg.InstallationID, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("app transport: %w", err)
+	}
+
+	return github.NewClient(&http.Client{Transport: tr}), nil
+}
+
+func newUpdateSources(
+	cfg UpdateConfig,
+	gh *github.Client,
+	ghLog zerolog.Logger,
+) (update.ReleaseSource, map[string]update.ReleaseSource, error) {
+	srcs := make(map[string]update.ReleaseSource)
+
+	newSrc := func(typ string) (update.ReleaseSource, error) {
+		if typ == "" {
+			typ = "github"
+		}
+
+		if src, ok := srcs[typ]; ok {
+			return src, nil
+		}
+
+		switch typ {
+		case "github":
+			srcs[typ] = update.NewGitHubSource(gh, ghLog)
+		case "fs":
+			if cfg.FS.Dir == "" {
+				return nil, errors.New("fs: empty dir")
+			}
+			srcs[typ] = update.NewFSSource(cfg.FS.Dir, cfg.FS.URL)
+		case "http":
+			if cfg.HTTP.URL == "" {
+				return nil, errors.New("http: empty url")
+			}
+			srcs[typ] = update.NewHTTPSource(cfg.HTTP.URL)
+		default:
+			return nil, fmt.Errorf("unknown source type: %s", typ)
+		}
+
+		return srcs[typ], nil
+	}
+
+	src, err := newSrc(cfg.Source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appSrc := make(map[string]update.ReleaseSource, len(cfg.Sources))
+	for app, typ := range cfg.Sources {
+		if appSrc[app], err = newSrc(typ); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", app, err)
+		}
+	}
+
+	return src, appSrc, nil
+}
@@ -1,14 +1,98 @@
 package app
 
+import (
+	"time"
+)
+
 type WeatherConfig struct {
 	APIKey string
 }
 
 type GitHubConfig struct {
-	Token string
+	Token string // personal access token; ignored if the app is configured
+
+	// GitHub App installation to authenticate as
+	AppID          int64
+	InstallationID int64
+	PrivateKeyFile string // PEM encoded app private key
+
+	WebhookSecret string // secret of release webhooks; the webhook endpoint is off if empty
+}
+
+type UpdateFSConfig struct {
+	Dir string // root of the `{owner}/{name}/{version}/{asset}` tree
+	URL string // base URL the tree is served from
+}
+
+type UpdateHTTPConfig struct {
+	URL string // base URL of `{owner}/{name}/index.json` files
+}
+
+type UpdateConfig struct {
+	Source          string            // default release source: github, fs or http; github if empty
+	Sources         map[string]string // release sources per app, keyed by `{owner}/{name}`
+	FS              UpdateFSConfig
+	HTTP            UpdateHTTPConfig
+	RefreshInterval time.Duration // how often releases are reloaded in background
+	MaxAge          time.Duration // max age of releases served without reloading them
+	PolicyFile      string        // path to a YAML or JSON file with app update policies, reloaded on change
+	StrictChecksums bool          // do not offer assets without valid SHA256 checksums
+	AllowAllApps    bool          // serve apps which are not listed in the policy too
+}
+
+type MirrorConfig struct {
+	Dir     string // directory to cache assets in; assets are served from their origins if empty
+	URL     string // public base URL of the download endpoint, e.g. `https://example.com/v2/firmware/download`
+	MaxSize int64  // cache size limit in bytes; unlimited if zero
+}
+
+type LinkConfig struct {
+	Secret      string        // secret to sign download links of private app assets with; links are off if empty
+	URL         string        // public URL of the asset endpoint, e.g. `https://example.com/v2/firmware/asset`
+	TTL         time.Duration // how long links are valid
+	PrivateApps []string      // apps which assets are served by links, as `{owner}/{name}`
+}
+
+type OperatorConfig struct {
+	Token string // bearer token of operator endpoints; the endpoints are off if empty
+}
+
+type ReportConfig struct {
+	File             string  // JSON lines file to persist reports to; reports are kept in memory only if empty
+	FailureThreshold float64 // failure rate, 0-1, at which rollout of a release is halted; 0 disables halting
+	MinReports       int     // number of finished installations required to evaluate the failure rate; 10 if zero
+}
+
+type InventoryConfig struct {
+	File              string // JSON lines file to persist devices to; devices are kept in memory only if empty
+	MaxDevices        int    // number of devices over which new devices are not recorded
+	AuthenticatedOnly bool   // record only devices authenticated by their credentials
+}
+
+type AuthConfig struct {
+	Secret      string // server secret signing keys of devices are derived from; device authentication is off if empty
+	File        string // JSON lines file to persist device credentials to; credentials are kept in memory only if empty
+	Strict      bool   // if set, device IDs of requests without credentials are ignored
+	Reports     bool   // if set, only authenticated devices may send reports
+	PrivateApps bool   // if set, releases of private apps are offered to authenticated devices only
+}
+
+type SigningConfig struct {
+	KeyID         string            // ID of the key devices look up the public key by
+	Key           string            // base64 encoded Ed25519 seed or private key; offers are not signed if empty
+	OfferTTL      time.Duration     // how long signed offers are valid
+	PublisherKeys map[string]string // base64 encoded Ed25519 public keys to verify `{asset}.sig` assets with
 }
 
 type Config struct {
-	Weather WeatherConfig
-	GitHub  GitHubConfig
+	Weather   WeatherConfig
+	GitHub    GitHubConfig
+	Update    UpdateConfig
+	Mirror    MirrorConfig
+	Link      LinkConfig
+	Report    ReportConfig
+	Inventory InventoryConfig
+	Auth      AuthConfig
+	Signing   SigningConfig
+	Operator  OperatorConfig
 }
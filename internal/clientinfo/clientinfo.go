@@ -10,27 +10,45 @@ import (
 )
 
 type Info struct {
-	RemoteAddr string
-	UserAgent  string
-	ID         string
-	Vendor     string
-	Name       string
-	Version    string
-	Hardware   string
-	Country    string
-	City       string
-	Timezone   string
+	RemoteAddr  string
+	UserAgent   string
+	ID          string
+	Vendor      string
+	Name        string
+	Version     string
+	Hardware    string
+	Country     string
+	CountryCode string
+	City        string
+	Timezone    string
+
+	// Authenticated is set if the device proved its ID with a credential; the ID is only claimed otherwise.
+	Authenticated bool
 }
 
 type ctxKeyType string
 
-const ctxKey ctxKeyType = "clientInfo"
+const (
+	ctxKey       ctxKeyType = "clientInfo"
+	deviceCtxKey ctxKeyType = "deviceID"
+)
 
 func FromCtx(ctx context.Context) Info {
 	v, _ := ctx.Value(ctxKey).(Info)
 	return v
 }
 
+// WithInfo returns a copy of the context carrying the client info.
+func WithInfo(ctx context.Context, ci Info) context.Context {
+	return context.WithValue(ctx, ctxKey, ci)
+}
+
+// WithDeviceID returns a copy of the context carrying the device ID verified by authentication.
+// FromRequest takes the ID instead of the one claimed by request headers and marks the client authenticated.
+func WithDeviceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, deviceCtxKey, id)
+}
+
 func FromRequest(req *http.Request, l zerolog.Logger) Info {
 	res := Info{
 		ID:         strings.TrimSpace(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer")),
@@ -38,6 +56,16 @@ func FromRequest(req *http.Request, l zerolog.Logger) Info {
 		UserAgent:  req.UserAgent(),
 	}
 
+	// Devices signing requests don't send tokens
+	if res.ID == "" {
+		res.ID = strings.TrimSpace(req.Header.Get("X-D5Y-Device"))
+	}
+
+	if id, ok := req.Context().Value(deviceCtxKey).(string); ok {
+		res.ID = id
+		res.Authenticated = true
+	}
+
 	if res.RemoteAddr == "" {
 		res.RemoteAddr = req.Header.Get("x-forwarded-for")
 	}
@@ -58,6 +86,7 @@ func FromRequest(req *http.Request, l zerolog.Logger) Info {
 		l.Error().Err(err).Msg("geoip lookup failed")
 	} else {
 		res.Country = gi.CountryName
+		res.CountryCode = gi.CountryCode
 		res.City = gi.City
 		res.Timezone = gi.Timezone
 	}
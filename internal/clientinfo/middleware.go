@@ -1,7 +1,6 @@
 package clientinfo
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/ashep/go-app/metrics"
@@ -25,6 +24,6 @@ func WrapHTTP(next http.HandlerFunc, l zerolog.Logger) http.HandlerFunc {
 
 		metrics.Counter("d5y_cloud_client_info", "D5Y Cloud client info", labels).With(labels).Inc()
 
-		next.ServeHTTP(rw, req.Clone(context.WithValue(req.Context(), ctxKey, ci)))
+		next.ServeHTTP(rw, req.Clone(WithInfo(req.Context(), ci)))
 	}
 }
@@ -0,0 +1,259 @@
+package devauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxClockSkew limits the difference between timestamps of signed requests and the server time.
+	maxClockSkew = 5 * time.Minute
+
+	// maxSignedBodySize limits the size of bodies of signed requests.
+	maxSignedBodySize = 1 << 20
+
+	minSecretSize = 16
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrNotFound           = errors.New("credential not found")
+	ErrInvalidDeviceID    = errors.New("invalid device id")
+
+	errUnknownDevice = fmt.Errorf("%w: unknown device", ErrInvalidCredentials)
+	errRevoked       = fmt.Errorf("%w: revoked", ErrInvalidCredentials)
+)
+
+// Credential is a device credential. Only the hash of the token secret is stored.
+type Credential struct {
+	DeviceID  string     `json:"device_id"`
+	TokenHash string     `json:"token_hash"` // hex SHA256 of the token secret
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Issued is a newly provisioned credential as it is handed to a device.
+type Issued struct {
+	DeviceID   string `json:"device_id"`
+	Token      string `json:"token"`       // `{device_id}.{secret}`, sent as a bearer token
+	SigningKey string `json:"signing_key"` // hex HMAC-SHA256 key to sign requests with instead of sending the token
+}
+
+// Service provisions and verifies device credentials.
+//
+// Devices authenticate either with the `Authorization: Bearer {token}` header, or by signing requests with the
+// `X-D5Y-Device`, `X-D5Y-Timestamp` and `X-D5Y-Signature` headers. Signing keys are derived from the server secret and
+// token hashes, so neither tokens nor signing keys can be recovered from the store.
+type Service struct {
+	secret []byte
+	st     Store
+}
+
+func New(secret string, st Store) (*Service, error) {
+	if len(secret) < minSecretSize {
+		return nil, fmt.Errorf("secret must be at least %d bytes long", minSecretSize)
+	}
+
+	return &Service{
+		secret: []byte(secret),
+		st:     st,
+	}, nil
+}
+
+// Provision issues a new credential of the device, replacing the previous one, if any.
+func (s *Service) Provision(ctx context.Context, devID string) (Issued, error) {
+	if devID == "" || strings.ContainsAny(devID, " \t\r\n") {
+		return Issued{}, ErrInvalidDeviceID
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Issued{}, fmt.Errorf("generate secret: %w", err)
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	cred := Credential{
+		DeviceID:  devID,
+		TokenHash: hashSecret(secret),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := s.st.Save(ctx, cred); err != nil {
+		return Issued{}, fmt.Errorf("save credential: %w", err)
+	}
+
+	return Issued{
+		DeviceID:   devID,
+		Token:      devID + "." + secret,
+		SigningKey: hex.EncodeToString(s.signingKey(cred)),
+	}, nil
+}
+
+// Revoke revokes the credential of the device.
+func (s *Service) Revoke(ctx context.Context, devID string) error {
+	cred, err := s.st.Get(ctx, devID)
+	if err != nil {
+		return err
+	}
+
+	if cred.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	cred.RevokedAt = &now
+
+	if err := s.st.Save(ctx, cred); err != nil {
+		return fmt.Errorf("save credential: %w", err)
+	}
+
+	return nil
+}
+
+// Authenticate verifies credentials of the request and returns the device ID.
+//
+// ErrNoCredentials is returned if the request carries no device token or signature, and does not claim the ID of
+// a device which has a credential. Bearer values which are not tokens of known devices are taken for bare device IDs
+// sent by old firmware.
+func (s *Service) Authenticate(req *http.Request) (string, error) {
+	if sig := req.Header.Get("X-D5Y-Signature"); sig != "" {
+		return s.authenticateSignature(req, sig)
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer"))
+	if token == "" {
+		return "", s.checkClaim(req.Context(), strings.TrimSpace(req.Header.Get("X-D5Y-Device")))
+	}
+
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return "", s.checkClaim(req.Context(), token)
+	}
+
+	cred, err := s.credential(req.Context(), token[:i])
+	if errors.Is(err, errUnknownDevice) {
+		return "", s.checkClaim(req.Context(), token)
+	} else if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(token[i+1:])), []byte(cred.TokenHash)) != 1 {
+		return "", ErrInvalidCredentials
+	}
+
+	return cred.DeviceID, nil
+}
+
+// checkClaim returns ErrNoCredentials if the device ID claimed without credentials may be trusted, i.e. no credential
+// was ever issued to the device; devices with revoked credentials are rejected too.
+func (s *Service) checkClaim(ctx context.Context, devID string) error {
+	if devID == "" {
+		return ErrNoCredentials
+	}
+
+	_, err := s.credential(ctx, devID)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: device must authenticate", ErrInvalidCredentials)
+	case errors.Is(err, errUnknownDevice):
+		return ErrNoCredentials
+	default:
+		return err
+	}
+}
+
+// authenticateSignature verifies the hex HMAC-SHA256 signature of `{method}\n{request uri}\n{timestamp}\n{body hash}`,
+// where the body hash is the hex SHA256 of the request body.
+func (s *Service) authenticateSignature(req *http.Request, sig string) (string, error) {
+	ts, err := strconv.ParseInt(req.Header.Get("X-D5Y-Timestamp"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid timestamp", ErrInvalidCredentials)
+	}
+
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", fmt.Errorf("%w: timestamp out of range", ErrInvalidCredentials)
+	}
+
+	sigB, err := hex.DecodeString(sig)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid signature", ErrInvalidCredentials)
+	}
+
+	cred, err := s.credential(req.Context(), req.Header.Get("X-D5Y-Device"))
+	if err != nil {
+		return "", err
+	}
+
+	body := []byte{}
+	if req.Body != nil {
+		if body, err = io.ReadAll(io.LimitReader(req.Body, maxSignedBodySize+1)); err != nil {
+			return "", fmt.Errorf("read body: %w", err)
+		}
+
+		if len(body) > maxSignedBodySize {
+			return "", fmt.Errorf("%w: body too large", ErrInvalidCredentials)
+		}
+
+		// The body is read again by handlers
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	bodySum := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, s.signingKey(cred))
+	mac.Write([]byte(req.Method + "\n" + req.URL.RequestURI() + "\n" + strconv.FormatInt(ts, 10) + "\n" +
+		hex.EncodeToString(bodySum[:])))
+
+	if !hmac.Equal(mac.Sum(nil), sigB) {
+		return "", fmt.Errorf("%w: signature mismatch", ErrInvalidCredentials)
+	}
+
+	return cred.DeviceID, nil
+}
+
+// credential returns the active credential of the device.
+func (s *Service) credential(ctx context.Context, devID string) (Credential, error) {
+	if devID == "" {
+		return Credential{}, fmt.Errorf("%w: empty device id", ErrInvalidCredentials)
+	}
+
+	cred, err := s.st.Get(ctx, devID)
+	if errors.Is(err, ErrNotFound) {
+		return Credential{}, errUnknownDevice
+	} else if err != nil {
+		return Credential{}, fmt.Errorf("get credential: %w", err)
+	}
+
+	if cred.RevokedAt != nil {
+		return Credential{}, errRevoked
+	}
+
+	return cred, nil
+}
+
+func (s *Service) signingKey(cred Credential) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("signing-key\n" + cred.DeviceID + "\n" + cred.TokenHash))
+
+	return mac.Sum(nil)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,272 @@
+package devauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef"
+
+// signRequest signs the request as devices do.
+func signRequest(t *testing.T, req *http.Request, devID, signingKey, body string, ts time.Time) {
+	t.Helper()
+
+	key, err := hex.DecodeString(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tsStr := strconv.FormatInt(ts.Unix(), 10)
+	bodySum := sha256.Sum256([]byte(body))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(req.Method + "\n" + req.URL.RequestURI() + "\n" + tsStr + "\n" + hex.EncodeToString(bodySum[:])))
+
+	req.Header.Set("X-D5Y-Device", devID)
+	req.Header.Set("X-D5Y-Timestamp", tsStr)
+	req.Header.Set("X-D5Y-Signature", hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestServiceAuthenticate(t *testing.T) {
+	ctx := context.Background()
+
+	svc, err := New(testSecret, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issued, err := svc.Provision(ctx, "dev1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := svc.Provision(ctx, "dev2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Revoke(ctx, "dev2"); err != nil {
+		t.Fatal(err)
+	}
+
+	otherSvc, err := New("fedcba9876543210", NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foreign, err := otherSvc.Provision(ctx, "dev1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		request func(t *testing.T) *http.Request
+		want    string
+		wantErr error
+	}{
+		{
+			name: "no credentials",
+			request: func(_ *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+			},
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name: "token",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("Authorization", "Bearer "+issued.Token)
+				return req
+			},
+			want: "dev1",
+		},
+		{
+			name: "wrong token secret",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("Authorization", "Bearer dev1.wrong")
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "revoked token",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("Authorization", "Bearer "+revoked.Token)
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "claim of unknown device",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("Authorization", "Bearer dev3")
+				return req
+			},
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name: "claim of dotted unknown device",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("Authorization", "Bearer dev.3")
+				return req
+			},
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name: "claim of provisioned device",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("Authorization", "Bearer dev1")
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "header claim of provisioned device",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("X-D5Y-Device", "dev1")
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "claim of revoked device",
+			request: func(_ *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				req.Header.Set("Authorization", "Bearer dev2")
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "signature",
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/v2/report?app=cronus", strings.NewReader("{}"))
+				signRequest(t, req, "dev1", issued.SigningKey, "{}", time.Now())
+				return req
+			},
+			want: "dev1",
+		},
+		{
+			name: "signature without body",
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update?app=cronus", nil)
+				signRequest(t, req, "dev1", issued.SigningKey, "", time.Now())
+				return req
+			},
+			want: "dev1",
+		},
+		{
+			name: "signature of another body",
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/v2/report", strings.NewReader(`{"status":"ok"}`))
+				signRequest(t, req, "dev1", issued.SigningKey, "{}", time.Now())
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "signature of another query",
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update?app=cronus", nil)
+				signRequest(t, req, "dev1", issued.SigningKey, "", time.Now())
+				req.URL.RawQuery = "app=other"
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "signature with key of another server",
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				signRequest(t, req, "dev1", foreign.SigningKey, "", time.Now())
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "expired signature",
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				signRequest(t, req, "dev1", issued.SigningKey, "", time.Now().Add(-2*maxClockSkew))
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "signature of revoked device",
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+				signRequest(t, req, "dev2", revoked.SigningKey, "", time.Now())
+				return req
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.Authenticate(tt.request(t))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("got device %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceProvisionReplacesCredential(t *testing.T) {
+	ctx := context.Background()
+
+	svc, err := New(testSecret, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := svc.Provision(ctx, "dev1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := svc.Provision(ctx, "dev1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.SigningKey == second.SigningKey {
+		t.Error("signing key is not replaced")
+	}
+
+	for _, tc := range []struct {
+		token   string
+		wantErr error
+	}{
+		{token: first.Token, wantErr: ErrInvalidCredentials},
+		{token: second.Token},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/update", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+
+		if _, err := svc.Authenticate(req); !errors.Is(err, tc.wantErr) {
+			t.Errorf("got error %v, want %v", err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package devauth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ashep/d5y/internal/api/rpcutil"
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/rs/zerolog"
+)
+
+// WrapHTTP authenticates devices; it must wrap clientinfo.WrapHTTP, so tokens never get into client info.
+//
+// Requests with invalid credentials are rejected, as well as requests claiming IDs of devices which have credentials.
+// Other requests without credentials are passed with the claimed device ID, or without any ID if strict is set.
+func WrapHTTP(next http.HandlerFunc, svc *Service, strict bool, l zerolog.Logger) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		devID, err := svc.Authenticate(req)
+		switch {
+		case errors.Is(err, ErrNoCredentials):
+			if strict {
+				req = req.Clone(req.Context())
+				req.Header.Del("Authorization")
+				req.Header.Del("X-D5Y-Device")
+			}
+		case err != nil:
+			l.Warn().
+				Err(err).
+				Str("req_method", req.Method).
+				Str("req_uri", req.RequestURI).
+				Str("remote_addr", req.RemoteAddr).
+				Msg("device authentication failed")
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		default:
+			req = req.Clone(clientinfo.WithDeviceID(req.Context(), devID))
+			req.Header.Set("Authorization", "Bearer "+devID)
+		}
+
+		next.ServeHTTP(rw, req)
+	}
+}
+
+// Require rejects requests of unauthenticated devices.
+func Require(next http.HandlerFunc, l zerolog.Logger) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !clientinfo.FromCtx(req.Context()).Authenticated {
+			ll := rpcutil.ReqLog(req, l)
+			ll.Warn().Err(errors.New("device not authenticated")).Msg("request rejected")
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	}
+}
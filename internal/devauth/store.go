@@ -0,0 +1,47 @@
+package devauth
+
+import (
+	"context"
+	"sync"
+)
+
+// Store keeps device credentials, one per device.
+type Store interface {
+	// Save creates or replaces the credential of the device.
+	Save(ctx context.Context, c Credential) error
+
+	// Get returns the credential of the device or ErrNotFound.
+	Get(ctx context.Context, devID string) (Credential, error)
+}
+
+// MemoryStore keeps credentials in memory.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	creds map[string]Credential
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		creds: make(map[string]Credential),
+	}
+}
+
+func (s *MemoryStore) Save(_ context.Context, c Credential) error {
+	s.mu.Lock()
+	s.creds[c.DeviceID] = c
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, devID string) (Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.creds[devID]
+	if !ok {
+		return Credential{}, ErrNotFound
+	}
+
+	return c, nil
+}
@@ -0,0 +1,80 @@
+package devauth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// FileStore keeps credentials in memory and appends them to a JSON lines file, which is replayed on start.
+type FileStore struct {
+	mem *MemoryStore
+	mu  sync.Mutex
+	f   *os.File
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	mem := NewMemoryStore()
+
+	rf, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("open file: %w", err)
+	} else if err == nil {
+		defer rf.Close() //nolint:errcheck // ok
+
+		sc := bufio.NewScanner(rf)
+		for sc.Scan() {
+			c := Credential{}
+			if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+				return nil, fmt.Errorf("unmarshal credential: %w", err)
+			}
+
+			if err := mem.Save(context.Background(), c); err != nil {
+				return nil, fmt.Errorf("replay credential: %w", err)
+			}
+		}
+
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+
+	return &FileStore{
+		mem: mem,
+		f:   f,
+	}, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, c Credential) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal credential: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write credential: %w", err)
+	}
+
+	return s.mem.Save(ctx, c)
+}
+
+func (s *FileStore) Get(ctx context.Context, devID string) (Credential, error) {
+	return s.mem.Get(ctx, devID)
+}
+
+func (s *FileStore) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,169 @@
+package ghapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v63/github"
+)
+
+const (
+	jwtTTL = 9 * time.Minute // GitHub accepts app JWTs valid for at most 10 minutes
+
+	// Clock drift allowance; GitHub recommends issuing JWTs in the past
+	jwtBackdate = time.Minute
+
+	// Tokens are refreshed before expiration, so requests in flight do not fail
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// Transport is an http.RoundTripper which authenticates requests with installation access tokens.
+// Tokens are issued on first use and refreshed before they expire.
+type Transport struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	base           http.RoundTripper
+	appCli         *github.Client
+	mu             sync.Mutex
+	token          string
+	expires        time.Time
+}
+
+// New creates a transport authenticating as the app installation with the PEM encoded app private key.
+// Requests are sent with base, or with http.DefaultTransport if base is nil.
+func New(appID, installationID int64, privateKey []byte, base http.RoundTripper) (*Transport, error) {
+	key, err := parseKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	t := &Transport{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		base:           base,
+	}
+
+	t.appCli = github.NewClient(&http.Client{Transport: appTransport{t: t}})
+
+	return t, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Token(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("get installation token: %w", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+
+	return t.base.RoundTrip(req)
+}
+
+// Token returns a valid installation access token.
+func (t *Transport) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Until(t.expires) > tokenRefreshMargin {
+		return t.token, nil
+	}
+
+	tok, _, err := t.appCli.Apps.CreateInstallationToken(ctx, t.installationID, nil)
+	if err != nil {
+		return "", err
+	}
+
+	t.token = tok.GetToken()
+	t.expires = tok.GetExpiresAt().Time
+
+	return t.token, nil
+}
+
+// jwt returns a token authenticating the app itself.
+func (t *Transport) jwt() (string, error) {
+	now := time.Now()
+
+	hdr, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-jwtBackdate).Unix(),
+		"exp": now.Add(jwtTTL).Unix(),
+		"iss": strconv.FormatInt(t.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	msg := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(msg))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, t.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign: %w", err)
+	}
+
+	return msg + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// appTransport authenticates requests as the app, which is required to issue installation tokens.
+type appTransport struct {
+	t *Transport
+}
+
+func (a appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := a.t.jwt()
+	if err != nil {
+		return nil, fmt.Errorf("create jwt: %w", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	return a.t.base.RoundTrip(req)
+}
+
+func parseKey(b []byte) (*rsa.PrivateKey, error) {
+	blk, _ := pem.Decode(b)
+	if blk == nil {
+		return nil, errors.New("invalid private key: no pem block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(blk.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(blk.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid private key: not an rsa key")
+	}
+
+	return rsaKey, nil
+}
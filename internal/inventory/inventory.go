@@ -0,0 +1,255 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// persistInterval limits how often devices are saved to the store if nothing but their last-seen times change.
+	persistInterval = 10 * time.Minute
+
+	// flushInterval is how often changed devices are saved to the store.
+	flushInterval = 10 * time.Second
+
+	defaultMaxDevices = 100000
+)
+
+// Device is a device known by its requests.
+type Device struct {
+	ID          string    `json:"id"`
+	Vendor      string    `json:"vendor,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Hardware    string    `json:"hardware,omitempty"`
+	Version     string    `json:"version,omitempty"` // last reported firmware version
+	RemoteAddr  string    `json:"remote_addr,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	CountryCode string    `json:"country_code,omitempty"`
+	City        string    `json:"city,omitempty"`
+	Timezone    string    `json:"timezone,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// Query filters devices. Empty fields match any device.
+type Query struct {
+	Version  string        // semver constraint, e.g. `0.3.x` or `<1.0.0`
+	Hardware string        // exact hardware ID
+	MaxAge   time.Duration // devices seen within the duration
+	MinAge   time.Duration // devices not seen for the duration
+}
+
+type Config struct {
+	MaxDevices        int  // devices over the limit are not recorded; defaultMaxDevices if zero
+	AuthenticatedOnly bool // if set, only authenticated devices are recorded
+}
+
+// Registry keeps devices in memory and persists them to the store in background, see Run.
+type Registry struct {
+	st        Store
+	cfg       Config
+	mu        sync.RWMutex
+	devices   map[string]*Device
+	persisted map[string]time.Time
+	dirty     map[string]struct{} // IDs of devices to save on the next flush
+	full      bool                // whether the limit of devices was hit, to report it once
+	l         zerolog.Logger
+}
+
+// New creates a registry with the devices loaded from the store.
+func New(ctx context.Context, st Store, cfg Config, l zerolog.Logger) (*Registry, error) {
+	devs, err := st.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load devices: %w", err)
+	}
+
+	if cfg.MaxDevices <= 0 {
+		cfg.MaxDevices = defaultMaxDevices
+	}
+
+	r := &Registry{
+		st:        st,
+		cfg:       cfg,
+		devices:   make(map[string]*Device, len(devs)),
+		persisted: make(map[string]time.Time, len(devs)),
+		dirty:     make(map[string]struct{}),
+		l:         l,
+	}
+
+	for _, d := range devs {
+		r.devices[d.ID] = &d
+		r.persisted[d.ID] = d.LastSeen
+	}
+
+	return r, nil
+}
+
+// Seen records a request of the device. Changes are saved to the store by Run.
+func (r *Registry) Seen(ci clientinfo.Info, t time.Time) {
+	if ci.ID == "" || (r.cfg.AuthenticatedOnly && !ci.Authenticated) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.devices[ci.ID]
+	if !ok && len(r.devices) >= r.cfg.MaxDevices {
+		if !r.full {
+			r.full = true
+			r.l.Warn().Int("max_devices", r.cfg.MaxDevices).Msg("device limit reached, new devices are not recorded")
+		}
+
+		return
+	}
+
+	if !ok {
+		d = &Device{ID: ci.ID, FirstSeen: t}
+		r.devices[ci.ID] = d
+	}
+
+	prev := *d
+
+	// Requests may lack some details, e.g. time requests don't report versions, so they don't erase known ones
+	if ci.Vendor != "" {
+		d.Vendor = ci.Vendor
+	}
+	if ci.Name != "" {
+		d.Name = ci.Name
+	}
+	if ci.Hardware != "" {
+		d.Hardware = ci.Hardware
+	}
+	if ci.Version != "" {
+		d.Version = ci.Version
+	}
+	if ci.RemoteAddr != "" {
+		d.RemoteAddr = ci.RemoteAddr
+	}
+	if ci.Country != "" {
+		d.Country = ci.Country
+	}
+	if ci.CountryCode != "" {
+		d.CountryCode = ci.CountryCode
+	}
+	if ci.City != "" {
+		d.City = ci.City
+	}
+	if ci.Timezone != "" {
+		d.Timezone = ci.Timezone
+	}
+
+	changed := !ok || prev != *d
+	d.LastSeen = t
+
+	if changed || t.Sub(r.persisted[ci.ID]) >= persistInterval {
+		r.persisted[ci.ID] = t
+		r.dirty[ci.ID] = struct{}{}
+	}
+}
+
+// Run saves changed devices to the store in background until ctx is done, then saves the remaining ones.
+func (r *Registry) Run(ctx context.Context) {
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.flush(context.WithoutCancel(ctx))
+			return
+		case <-t.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+// flush saves changed devices to the store. Devices failed to save are saved on the next flush.
+func (r *Registry) flush(ctx context.Context) {
+	r.mu.Lock()
+	devs := make([]Device, 0, len(r.dirty))
+	for id := range r.dirty {
+		devs = append(devs, *r.devices[id])
+	}
+	clear(r.dirty)
+	r.mu.Unlock()
+
+	for _, d := range devs {
+		if err := r.st.Save(ctx, d); err != nil {
+			r.l.Error().Err(err).Str("device_id", d.ID).Msg("failed to save device")
+
+			r.mu.Lock()
+			r.dirty[d.ID] = struct{}{}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// Known reports whether the device was recorded before its latest request, i.e. it is not new to the inventory.
+func (r *Registry) Known(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.devices[id]
+
+	return ok && d.FirstSeen.Before(d.LastSeen)
+}
+
+// Find returns the devices matching the query, sorted by ID.
+func (r *Registry) Find(q Query, now time.Time) ([]Device, error) {
+	var verC *semver.Constraints
+	if q.Version != "" {
+		var err error
+		if verC, err = semver.NewConstraint(q.Version); err != nil {
+			return nil, fmt.Errorf("invalid version constraint: %w", err)
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]Device, 0)
+
+	for _, d := range r.devices {
+		if q.Hardware != "" && !strings.EqualFold(q.Hardware, d.Hardware) {
+			continue
+		}
+
+		age := now.Sub(d.LastSeen)
+		if (q.MaxAge > 0 && age > q.MaxAge) || (q.MinAge > 0 && age < q.MinAge) {
+			continue
+		}
+
+		if verC != nil {
+			ver, err := semver.NewVersion(d.Version)
+			if err != nil || !verC.Check(ver) {
+				continue
+			}
+		}
+
+		res = append(res, *d)
+	}
+
+	slices.SortFunc(res, func(a, b Device) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return res, nil
+}
+
+// WrapHTTP records devices making requests. It must be wrapped by clientinfo.WrapHTTP.
+func WrapHTTP(next http.HandlerFunc, r *Registry) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		r.Seen(clientinfo.FromCtx(req.Context()), time.Now())
+		next.ServeHTTP(rw, req)
+	}
+}
@@ -0,0 +1,87 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/rs/zerolog"
+)
+
+func TestRegistrySeen(t *testing.T) {
+	st := NewMemoryStore()
+
+	r, err := New(context.Background(), st, Config{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	r.Seen(clientinfo.Info{ID: "dev1", Hardware: "esp32", Version: "1.0.0"}, t0)
+	r.Seen(clientinfo.Info{ID: "dev1", City: "Kyiv"}, t0.Add(time.Minute))
+
+	if n := st.Len(); n != 0 {
+		t.Fatalf("got %d saved devices before flush, want 0", n)
+	}
+
+	r.flush(context.Background())
+
+	devs, err := st.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Device{
+		ID:        "dev1",
+		Hardware:  "esp32",
+		Version:   "1.0.0",
+		City:      "Kyiv",
+		FirstSeen: t0,
+		LastSeen:  t0.Add(time.Minute),
+	}
+
+	if len(devs) != 1 || devs[0] != want {
+		t.Fatalf("got %+v, want %+v", devs, want)
+	}
+
+	// Only last-seen times change, so the device is saved again after persistInterval
+	r.Seen(clientinfo.Info{ID: "dev1"}, t0.Add(2*time.Minute))
+	r.flush(context.Background())
+
+	if devs, _ := st.List(context.Background()); !devs[0].LastSeen.Equal(t0.Add(time.Minute)) {
+		t.Errorf("got last seen %s, want %s", devs[0].LastSeen, t0.Add(time.Minute))
+	}
+
+	r.Seen(clientinfo.Info{ID: "dev1"}, t0.Add(time.Minute+persistInterval))
+	r.flush(context.Background())
+
+	if devs, _ := st.List(context.Background()); !devs[0].LastSeen.Equal(t0.Add(time.Minute + persistInterval)) {
+		t.Errorf("got last seen %s, want %s", devs[0].LastSeen, t0.Add(time.Minute+persistInterval))
+	}
+}
+
+func TestRegistryRunFlushesOnStop(t *testing.T) {
+	st := NewMemoryStore()
+
+	r, err := New(context.Background(), st, Config{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	r.Seen(clientinfo.Info{ID: "dev1"}, time.Now())
+	cancel()
+	<-done
+
+	if n := st.Len(); n != 1 {
+		t.Errorf("got %d saved devices, want 1", n)
+	}
+}
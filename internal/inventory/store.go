@@ -0,0 +1,55 @@
+package inventory
+
+import (
+	"context"
+	"sync"
+)
+
+// Store keeps devices.
+type Store interface {
+	// Save creates or replaces the device.
+	Save(ctx context.Context, d Device) error
+
+	// List returns all the devices.
+	List(ctx context.Context) ([]Device, error)
+}
+
+// MemoryStore keeps devices in memory.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	devices map[string]Device
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		devices: make(map[string]Device),
+	}
+}
+
+func (s *MemoryStore) Save(_ context.Context, d Device) error {
+	s.mu.Lock()
+	s.devices[d.ID] = d
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) List(_ context.Context) ([]Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		res = append(res, d)
+	}
+
+	return res, nil
+}
+
+// Len returns the number of devices.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.devices)
+}
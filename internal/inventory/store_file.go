@@ -0,0 +1,157 @@
+package inventory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// minCompactLines is the number of lines below which the file is never compacted.
+const minCompactLines = 1000
+
+// FileStore keeps devices in memory and appends them to a JSON lines file, which is replayed on start.
+// The file is rewritten with the latest device states when it grows much larger than the number of devices.
+type FileStore struct {
+	mem   *MemoryStore
+	path  string
+	mu    sync.Mutex
+	f     *os.File
+	lines int
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		mem:  NewMemoryStore(),
+		path: path,
+	}
+
+	rf, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("open file: %w", err)
+	} else if err == nil {
+		defer rf.Close() //nolint:errcheck // ok
+
+		sc := bufio.NewScanner(rf)
+		for sc.Scan() {
+			d := Device{}
+			if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+				return nil, fmt.Errorf("unmarshal device: %w", err)
+			}
+
+			if err := s.mem.Save(context.Background(), d); err != nil {
+				return nil, fmt.Errorf("replay device: %w", err)
+			}
+		}
+
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, d Device) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("marshal device: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The memory is updated under the lock too, so the file gets states of a device in the same order
+	if err := s.mem.Save(ctx, d); err != nil {
+		return err
+	}
+
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write device: %w", err)
+	}
+
+	s.lines++
+
+	if s.lines >= minCompactLines && s.lines > 4*s.mem.Len() {
+		return s.compact()
+	}
+
+	return nil
+}
+
+func (s *FileStore) List(ctx context.Context) ([]Device, error) {
+	return s.mem.List(ctx)
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+// compact replaces the file with the one containing only the latest device states.
+// It must be called with mu locked, unless the store is being created.
+func (s *FileStore) compact() error {
+	devs, err := s.mem.List(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck // ok, the file is renamed on success
+
+	w := bufio.NewWriter(tmp)
+	for _, d := range devs {
+		b, err := json.Marshal(d)
+		if err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("marshal device: %w", err)
+		}
+
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+
+	if s.f != nil {
+		_ = s.f.Close()
+	}
+
+	s.f = f
+	s.lines = len(devs)
+
+	return nil
+}
@@ -0,0 +1,311 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrNotFound         = errors.New("asset not found")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrTooLarge         = errors.New("asset too large")
+)
+
+// downloadTimeout limits the time of downloading an asset from its origin.
+const downloadTimeout = 10 * time.Minute
+
+type origin struct {
+	url   string // URL the asset is served from if it is not mirrored
+	fetch func(ctx context.Context) (io.ReadCloser, error)
+}
+
+type entry struct {
+	size     int64
+	lastUsed time.Time
+}
+
+// Mirror is a content-addressed cache of assets stored in a local directory as `{dir}/{sha256}` files.
+//
+// Assets are registered with their checksums and fetched from their origins on first request.
+// A downloaded asset is stored only if its checksum matches. Least recently used assets are evicted when the total
+// size of the cache exceeds the limit. Assets larger than the limit are not mirrored.
+type Mirror struct {
+	dir      string
+	baseURL  string
+	maxSize  int64
+	sf       singleflight.Group
+	mu       sync.Mutex
+	origins  map[string]origin
+	entries  map[string]*entry
+	tooLarge map[string]struct{} // checksums of assets found larger than the limit on download
+	size     int64
+	l        zerolog.Logger
+}
+
+// New creates a mirror which serves assets from `{baseURL}/{sha256}` URLs.
+// Files found in the directory are verified and added to the cache, invalid ones are removed.
+func New(dir, baseURL string, maxSize int64, l zerolog.Logger) (*Mirror, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+
+	m := &Mirror{
+		dir:      dir,
+		baseURL:  baseURL,
+		maxSize:  maxSize,
+		origins:  make(map[string]origin),
+		entries:  make(map[string]*entry),
+		tooLarge: make(map[string]struct{}),
+		l:        l,
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+
+	for _, f := range files {
+		if !f.Type().IsRegular() {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+
+		size, err := verifyFile(path, f.Name())
+		if err != nil {
+			l.Warn().Err(err).Str("path", path).Msg("removing invalid mirrored asset")
+			_ = os.Remove(path)
+			continue
+		}
+
+		fi, err := f.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat file: %w", err)
+		}
+
+		m.entries[f.Name()] = &entry{size: size, lastUsed: fi.ModTime()}
+		m.size += size
+	}
+
+	m.mu.Lock()
+	m.evict()
+	m.mu.Unlock()
+
+	return m, nil
+}
+
+// Add registers the origin URL of the asset and the function opening it there, and returns the URL the asset is served
+// from. An empty string is returned if the asset is larger than the cache size limit, so it must be served from the
+// origin. The size is unknown if zero.
+func (m *Mirror) Add(
+	sum string,
+	size int64,
+	originURL string,
+	fetch func(ctx context.Context) (io.ReadCloser, error),
+) string {
+	m.mu.Lock()
+	m.origins[sum] = origin{url: originURL, fetch: fetch}
+	_, tooLarge := m.tooLarge[sum]
+	m.mu.Unlock()
+
+	if tooLarge || (m.maxSize > 0 && size > m.maxSize) {
+		return ""
+	}
+
+	u, err := url.JoinPath(m.baseURL, sum)
+	if err != nil {
+		m.l.Error().Err(err).Msg("failed to build mirrored asset url")
+		return ""
+	}
+
+	return u
+}
+
+// Open returns the cached asset, downloading it from the origin if needed.
+// ErrTooLarge is returned if the asset does not fit the cache size limit; it is served from OriginURL then.
+func (m *Mirror) Open(ctx context.Context, sum string) (*os.File, error) {
+	m.mu.Lock()
+	_, cached := m.entries[sum]
+	orig, known := m.origins[sum]
+	_, tooLarge := m.tooLarge[sum]
+	m.mu.Unlock()
+
+	if tooLarge {
+		return nil, ErrTooLarge
+	}
+
+	if !cached && !known {
+		return nil, ErrNotFound
+	}
+
+	if !cached {
+		_, err, _ := m.sf.Do(sum, func() (any, error) {
+			// Downloads are shared by requests, so they must not be canceled with one of them
+			return nil, m.download(context.WithoutCancel(ctx), sum, orig.fetch)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(filepath.Join(m.dir, sum))
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+
+	m.mu.Lock()
+	if e, ok := m.entries[sum]; ok {
+		e.lastUsed = time.Now()
+	}
+	m.mu.Unlock()
+
+	return f, nil
+}
+
+func (m *Mirror) download(ctx context.Context, sum string, fetch func(ctx context.Context) (io.ReadCloser, error)) error {
+	m.mu.Lock()
+	_, ok := m.entries[sum]
+	m.mu.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	// Requests wait for the download, so a hung origin must not block them forever
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	rc, err := fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+
+	defer rc.Close() //nolint:errcheck // ok
+
+	tmp, err := os.CreateTemp(m.dir, ".download-*")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck // ok, the file is renamed on success
+
+	h := sha256.New()
+
+	var r io.Reader = rc
+	if m.maxSize > 0 {
+		r = io.LimitReader(rc, m.maxSize+1)
+	}
+
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+
+	// The asset would be evicted right after the download, so it is never downloaded again
+	if m.maxSize > 0 && size > m.maxSize {
+		m.mu.Lock()
+		m.tooLarge[sum] = struct{}{}
+		m.mu.Unlock()
+
+		m.l.Warn().Str("sha256", sum).Int64("max_size", m.maxSize).Msg("asset too large to mirror")
+
+		return ErrTooLarge
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return ErrChecksumMismatch
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(m.dir, sum)); err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+
+	m.mu.Lock()
+	m.entries[sum] = &entry{size: size, lastUsed: time.Now()}
+	m.size += size
+	m.evict()
+	m.mu.Unlock()
+
+	m.l.Info().Str("sha256", sum).Int64("size", size).Msg("asset mirrored")
+
+	return nil
+}
+
+// OriginURL returns the URL the asset is served from if it is not mirrored, or an empty string if it is unknown.
+func (m *Mirror) OriginURL(sum string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.origins[sum].url
+}
+
+// evict removes least recently used assets until the cache fits the size limit. It must be called with mu locked.
+func (m *Mirror) evict() {
+	if m.maxSize <= 0 || m.size <= m.maxSize {
+		return
+	}
+
+	sums := make([]string, 0, len(m.entries))
+	for sum := range m.entries {
+		sums = append(sums, sum)
+	}
+
+	slices.SortFunc(sums, func(a, b string) int {
+		return m.entries[a].lastUsed.Compare(m.entries[b].lastUsed)
+	})
+
+	for _, sum := range sums {
+		if m.size <= m.maxSize {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(m.dir, sum)); err != nil {
+			m.l.Error().Err(err).Str("sha256", sum).Msg("failed to evict mirrored asset")
+			continue
+		}
+
+		m.size -= m.entries[sum].size
+		delete(m.entries, sum)
+
+		m.l.Info().Str("sha256", sum).Msg("mirrored asset evicted")
+	}
+}
+
+func verifyFile(path, sum string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close() //nolint:errcheck // ok
+
+	h := sha256.New()
+
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, err
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return 0, ErrChecksumMismatch
+	}
+
+	return size, nil
+}
@@ -0,0 +1,182 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/go-app/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// defaultMinReports is the default number of finished installations required to evaluate the failure rate.
+const defaultMinReports = 10
+
+var ErrInvalidReport = errors.New("invalid report")
+
+// Stage is a stage of a firmware update on a device.
+type Stage string
+
+const (
+	StageDownloading Stage = "downloading"
+	StageVerified    Stage = "verified"
+	StageInstalled   Stage = "installed"
+	StageFailed      Stage = "failed"
+)
+
+// Report is a firmware update status reported by a device.
+type Report struct {
+	DeviceID string    `json:"device_id"`
+	Owner    string    `json:"owner"`
+	Name     string    `json:"name"`
+	Version  string    `json:"version"` // version being installed
+	Stage    Stage     `json:"stage"`
+	Code     int       `json:"code,omitempty"` // device specific failure code
+	Time     time.Time `json:"time"`
+
+	// Verified is set if the device is authenticated or known by earlier requests; only verified reports are stored.
+	Verified bool `json:"-"`
+}
+
+func (r Report) validate() error {
+	if r.DeviceID == "" {
+		return errors.New("empty device id")
+	}
+
+	if r.Owner == "" || r.Name == "" {
+		return errors.New("empty app")
+	}
+
+	if _, err := semver.NewVersion(r.Version); err != nil {
+		return fmt.Errorf("version: %w", err)
+	}
+
+	switch r.Stage {
+	case StageDownloading, StageVerified, StageInstalled, StageFailed:
+	default:
+		return fmt.Errorf("unknown stage: %s", r.Stage)
+	}
+
+	return nil
+}
+
+type Config struct {
+	// FailureThreshold is the failure rate, 0-1, at which rollout of a release is halted; 0 disables halting.
+	FailureThreshold float64
+
+	// MinReports is the number of finished installations required to evaluate the failure rate; defaultMinReports if
+	// zero.
+	MinReports int
+}
+
+type Service struct {
+	st  Store
+	cfg Config
+	l   zerolog.Logger
+}
+
+func New(st Store, cfg Config, l zerolog.Logger) *Service {
+	if cfg.MinReports <= 0 {
+		cfg.MinReports = defaultMinReports
+	}
+
+	return &Service{
+		st:  st,
+		cfg: cfg,
+		l:   l,
+	}
+}
+
+// Add saves a report of the device. Reports which are not verified are only counted by metrics, so devices cannot halt
+// rollouts by claiming arbitrary IDs.
+func (s *Service) Add(ctx context.Context, r Report) error {
+	// Versions are kept in the canonical form, so reports about `v1.0.0` and `1.0.0` are the same
+	r.Version = canonicalVersion(r.Version)
+
+	if err := r.validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidReport, err)
+	}
+
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	labels := prometheus.Labels{
+		"app":     r.Owner + "/" + r.Name,
+		"version": r.Version,
+		"stage":   string(r.Stage),
+	}
+	metrics.Counter("d5y_cloud_firmware_report", "D5Y Cloud firmware update reports", labels).With(labels).Inc()
+
+	if !r.Verified {
+		s.l.Debug().Str("device_id", r.DeviceID).Msg("report of unverified device is not stored")
+		return nil
+	}
+
+	if err := s.st.Add(ctx, r); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+
+	if r.Stage == StageFailed && s.Halted(r.Owner, r.Name, r.Version) {
+		s.l.Warn().
+			Str("app", r.Owner+"/"+r.Name).
+			Str("version", r.Version).
+			Msg("release rollout is halted due to failure rate")
+	}
+
+	return nil
+}
+
+// Halted reports whether rollout of the release is halted because too many devices failed to install it.
+func (s *Service) Halted(owner, name, version string) bool {
+	st, err := s.st.Stats(context.Background(), owner, name, version)
+	if err != nil {
+		s.l.Error().Err(err).Str("app", owner+"/"+name).Str("version", version).Msg("failed to get release stats")
+		return false
+	}
+
+	return s.halted(st)
+}
+
+// Stats returns the statistics of the release and whether its rollout is halted.
+func (s *Service) Stats(ctx context.Context, owner, name, version string) (Stats, bool, error) {
+	st, err := s.st.Stats(ctx, owner, name, canonicalVersion(version))
+	if err != nil {
+		return Stats{}, false, fmt.Errorf("store: %w", err)
+	}
+
+	return st, s.halted(st), nil
+}
+
+// Resume lifts the halt of the release by forgetting reports about it; the failure rate is evaluated anew.
+func (s *Service) Resume(ctx context.Context, owner, name, version string) error {
+	version = canonicalVersion(version)
+
+	if err := s.st.Reset(ctx, owner, name, version); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+
+	s.l.Info().Str("app", owner+"/"+name).Str("version", version).Msg("release rollout resumed")
+
+	return nil
+}
+
+func (s *Service) halted(st Stats) bool {
+	if s.cfg.FailureThreshold <= 0 || st.Installed+st.Failed < s.cfg.MinReports {
+		return false
+	}
+
+	return st.FailureRate() >= s.cfg.FailureThreshold
+}
+
+// canonicalVersion returns the canonical form of the version, or the version as is if it is invalid.
+func canonicalVersion(version string) string {
+	if ver, err := semver.NewVersion(version); err == nil {
+		return ver.String()
+	}
+
+	return version
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func addReports(t *testing.T, svc *Service, stage Stage, n int, verified bool) {
+	t.Helper()
+
+	for i := range n {
+		err := svc.Add(context.Background(), Report{
+			DeviceID: fmt.Sprintf("%s-%d", stage, i),
+			Owner:    "ashep",
+			Name:     "cronus",
+			Version:  "v1.1.0",
+			Stage:    stage,
+			Verified: verified,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestServiceHalted(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		installed  int
+		failed     int
+		unverified int // failures of unverified devices
+		want       bool
+	}{
+		{name: "halting off", cfg: Config{}, failed: 20},
+		{name: "single failure", cfg: Config{FailureThreshold: 0.2}, failed: 1},
+		{name: "below min reports", cfg: Config{FailureThreshold: 0.2}, installed: 5, failed: 4},
+		{name: "failure rate reached", cfg: Config{FailureThreshold: 0.2}, installed: 8, failed: 2, want: true},
+		{name: "failure rate not reached", cfg: Config{FailureThreshold: 0.2}, installed: 9, failed: 1},
+		{name: "custom min reports", cfg: Config{FailureThreshold: 0.2, MinReports: 1}, failed: 1, want: true},
+		{name: "unverified failures", cfg: Config{FailureThreshold: 0.2}, installed: 8, unverified: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := New(NewMemoryStore(), tt.cfg, zerolog.Nop())
+
+			addReports(t, svc, StageInstalled, tt.installed, true)
+			addReports(t, svc, StageFailed, tt.failed, true)
+			addReports(t, svc, StageFailed, tt.unverified, false)
+
+			if got := svc.Halted("ashep", "cronus", "1.1.0"); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.jsonl")
+	cfg := Config{FailureThreshold: 0.2}
+
+	st, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New(st, cfg, zerolog.Nop())
+	addReports(t, svc, StageFailed, 10, true)
+
+	if !svc.Halted("ashep", "cronus", "1.1.0") {
+		t.Fatal("not halted")
+	}
+
+	if err := svc.Resume(context.Background(), "ashep", "cronus", "v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if svc.Halted("ashep", "cronus", "1.1.0") {
+		t.Error("halted after resume")
+	}
+
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The resume survives restarts
+	st, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer st.Close() //nolint:errcheck // ok
+
+	svc = New(st, cfg, zerolog.Nop())
+
+	if svc.Halted("ashep", "cronus", "1.1.0") {
+		t.Error("halted after restart")
+	}
+
+	addReports(t, svc, StageFailed, 10, true)
+
+	if !svc.Halted("ashep", "cronus", "1.1.0") {
+		t.Error("not halted by new reports")
+	}
+}
+
+func TestMemoryStoreDeviceLimit(t *testing.T) {
+	st := NewMemoryStore()
+
+	for i := range maxReleaseDevices + 10 {
+		r := Report{DeviceID: fmt.Sprintf("dev%d", i), Owner: "ashep", Name: "cronus", Version: "1.1.0", Stage: StageFailed}
+		if err := st.Add(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := st.Stats(context.Background(), "ashep", "cronus", "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Devices != maxReleaseDevices || stats.Failed != maxReleaseDevices {
+		t.Errorf("got %+v, want %d devices", stats, maxReleaseDevices)
+	}
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"context"
+	"sync"
+)
+
+// maxReleaseDevices limits the number of devices which reports about a release are kept.
+const maxReleaseDevices = 100000
+
+// Stats summarizes reports about a release.
+type Stats struct {
+	Devices   int // number of devices which reported
+	Installed int // number of devices which installed the release
+	Failed    int // number of devices which failed to install the release
+}
+
+// FailureRate returns the share of failed installations among finished ones.
+func (s Stats) FailureRate() float64 {
+	if s.Installed+s.Failed == 0 {
+		return 0
+	}
+
+	return float64(s.Failed) / float64(s.Installed+s.Failed)
+}
+
+// Store keeps device reports.
+type Store interface {
+	// Add saves the report.
+	Add(ctx context.Context, r Report) error
+
+	// Stats returns the statistics of the release, based on the latest report of every device.
+	Stats(ctx context.Context, owner, name, version string) (Stats, error)
+
+	// Reset forgets reports about the release.
+	Reset(ctx context.Context, owner, name, version string) error
+}
+
+type releaseReports struct {
+	stages map[string]Stage // latest stages keyed by device ID
+	stats  Stats
+}
+
+// MemoryStore keeps reports in memory. Reports of devices over maxReleaseDevices per release are ignored; the
+// statistics of the first devices are representative enough.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	rels map[string]*releaseReports
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rels: make(map[string]*releaseReports),
+	}
+}
+
+func (s *MemoryStore) Add(_ context.Context, r Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Owner + "/" + r.Name + "/" + r.Version
+
+	rel, ok := s.rels[key]
+	if !ok {
+		rel = &releaseReports{stages: make(map[string]Stage)}
+		s.rels[key] = rel
+	}
+
+	prev, ok := rel.stages[r.DeviceID]
+	if !ok && len(rel.stages) >= maxReleaseDevices {
+		return nil
+	} else if !ok {
+		rel.stats.Devices++
+	}
+
+	rel.stats.count(prev, -1)
+	rel.stats.count(r.Stage, 1)
+	rel.stages[r.DeviceID] = r.Stage
+
+	return nil
+}
+
+func (s *MemoryStore) Stats(_ context.Context, owner, name, version string) (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rel, ok := s.rels[owner+"/"+name+"/"+version]
+	if !ok {
+		return Stats{}, nil
+	}
+
+	return rel.stats, nil
+}
+
+func (s *MemoryStore) Reset(_ context.Context, owner, name, version string) error {
+	s.mu.Lock()
+	delete(s.rels, owner+"/"+name+"/"+version)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *Stats) count(stage Stage, delta int) {
+	switch stage {
+	case StageInstalled:
+		s.Installed += delta
+	case StageFailed:
+		s.Failed += delta
+	}
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileRecord is a line of the file: a report, or a reset of reports about the release.
+type fileRecord struct {
+	Report
+	Reset bool `json:"reset,omitempty"`
+}
+
+// FileStore keeps reports in memory and appends them to a JSON lines file, which is replayed on start.
+// Resets of release reports are appended too.
+type FileStore struct {
+	mem *MemoryStore
+	mu  sync.Mutex
+	f   *os.File
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	mem := NewMemoryStore()
+
+	rf, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("open file: %w", err)
+	} else if err == nil {
+		defer rf.Close() //nolint:errcheck // ok
+
+		sc := bufio.NewScanner(rf)
+		for sc.Scan() {
+			rec := fileRecord{}
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				return nil, fmt.Errorf("unmarshal report: %w", err)
+			}
+
+			if rec.Reset {
+				err = mem.Reset(context.Background(), rec.Owner, rec.Name, rec.Version)
+			} else {
+				err = mem.Add(context.Background(), rec.Report)
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("replay report: %w", err)
+			}
+		}
+
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+
+	return &FileStore{
+		mem: mem,
+		f:   f,
+	}, nil
+}
+
+func (s *FileStore) Add(ctx context.Context, r Report) error {
+	if err := s.write(fileRecord{Report: r}); err != nil {
+		return err
+	}
+
+	return s.mem.Add(ctx, r)
+}
+
+func (s *FileStore) Reset(ctx context.Context, owner, name, version string) error {
+	rec := fileRecord{
+		Report: Report{Owner: owner, Name: name, Version: version, Time: time.Now()},
+		Reset:  true,
+	}
+
+	if err := s.write(rec); err != nil {
+		return err
+	}
+
+	return s.mem.Reset(ctx, owner, name, version)
+}
+
+func (s *FileStore) write(rec fileRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+
+	s.mu.Lock()
+	_, err = s.f.Write(append(b, '\n'))
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) Stats(ctx context.Context, owner, name, version string) (Stats, error) {
+	return s.mem.Stats(ctx, owner, name, version)
+}
+
+func (s *FileStore) Close() error {
+	return s.f.Close()
+}
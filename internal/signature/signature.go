@@ -0,0 +1,104 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signer signs messages with an Ed25519 key.
+//
+// Keys are identified by IDs, so devices may trust several public keys during a key rotation: a new key is shipped
+// to devices with a firmware update first, then the server is switched to it.
+type Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewSigner creates a signer from a base64 encoded Ed25519 seed or private key.
+func NewSigner(keyID, key string) (*Signer, error) {
+	if keyID == "" {
+		return nil, errors.New("empty key id")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+
+	var pk ed25519.PrivateKey
+
+	switch len(b) {
+	case ed25519.SeedSize:
+		pk = ed25519.NewKeyFromSeed(b)
+	case ed25519.PrivateKeySize:
+		pk = b
+	default:
+		return nil, fmt.Errorf("invalid key length: %d", len(b))
+	}
+
+	return &Signer{
+		keyID: keyID,
+		key:   pk,
+	}, nil
+}
+
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey returns the base64 encoded public key.
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey)) //nolint:forcetypeassert // ok
+}
+
+// Sign returns the base64 encoded signature of the message.
+func (s *Signer) Sign(msg []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, msg))
+}
+
+// Verifier checks signatures against a set of trusted Ed25519 public keys.
+type Verifier struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// NewVerifier creates a verifier from base64 encoded public keys keyed by their IDs.
+func NewVerifier(keys map[string]string) (*Verifier, error) {
+	v := &Verifier{
+		keys: make(map[string]ed25519.PublicKey, len(keys)),
+	}
+
+	for id, key := range keys {
+		b, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: decode key: %w", id, err)
+		}
+
+		if len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: invalid key length: %d", id, len(b))
+		}
+
+		v.keys[id] = b
+	}
+
+	return v, nil
+}
+
+// Verify checks the base64 encoded signature of the message and returns the ID of the key it is made with.
+func (v *Verifier) Verify(msg []byte, sig string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	for id, key := range v.keys {
+		if ed25519.Verify(key, msg, b) {
+			return id, nil
+		}
+	}
+
+	return "", ErrInvalidSignature
+}
@@ -0,0 +1,100 @@
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("link expired")
+)
+
+// Signer issues download URLs of app assets which are valid for a limited time.
+//
+// A URL carries the app, the asset reference and the expiration time, signed with HMAC-SHA256.
+type Signer struct {
+	secret  []byte
+	baseURL string
+	ttl     time.Duration
+}
+
+// New creates a signer issuing URLs of the download endpoint at baseURL.
+func New(secret, baseURL string, ttl time.Duration) (*Signer, error) {
+	if secret == "" {
+		return nil, errors.New("empty secret")
+	}
+
+	if _, err := url.Parse(baseURL); err != nil || baseURL == "" {
+		return nil, errors.New("invalid base url")
+	}
+
+	return &Signer{
+		secret:  []byte(secret),
+		baseURL: baseURL,
+		ttl:     ttl,
+	}, nil
+}
+
+// Link returns a signed download URL of the asset of the app.
+func (s *Signer) Link(owner, name, ref string) string {
+	app := owner + "/" + name
+	expires := strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+
+	q := url.Values{}
+	q.Set("app", app)
+	q.Set("ref", ref)
+	q.Set("expires", expires)
+	q.Set("sig", s.sign(app, ref, expires))
+
+	sep := "?"
+	if strings.Contains(s.baseURL, "?") {
+		sep = "&"
+	}
+
+	return s.baseURL + sep + q.Encode()
+}
+
+// Verify checks the query of a signed URL and returns the app owner, name and the asset reference.
+func (s *Signer) Verify(q url.Values) (string, string, string, error) {
+	app, ref, expires := q.Get("app"), q.Get("ref"), q.Get("expires")
+
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get("sig"))
+	if err != nil {
+		return "", "", "", ErrInvalidSignature
+	}
+
+	want, _ := base64.RawURLEncoding.DecodeString(s.sign(app, ref, expires))
+	if !hmac.Equal(sig, want) {
+		return "", "", "", ErrInvalidSignature
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", "", "", ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > exp {
+		return "", "", "", ErrExpired
+	}
+
+	owner, name, ok := strings.Cut(app, "/")
+	if !ok {
+		return "", "", "", ErrInvalidSignature
+	}
+
+	return owner, name, ref, nil
+}
+
+func (s *Signer) sign(app, ref, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(app + "\n" + ref + "\n" + expires))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,102 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type refCacheItem[V any] struct {
+	value   V
+	err     error
+	expires time.Time
+}
+
+// refCache keeps values read from release assets, such as checksums and manifests, keyed by asset refs.
+//
+// Items expire, so assets replaced at the same ref are read again. Failed fetches are cached for a shorter time, so
+// a broken asset is not refetched on every refresh. Concurrent fetches of the same ref are deduplicated.
+type refCache[V any] struct {
+	size   int
+	ttl    time.Duration
+	errTTL time.Duration
+	sf     singleflight.Group
+	mu     sync.Mutex
+	items  map[string]refCacheItem[V]
+}
+
+func newRefCache[V any](size int, ttl, errTTL time.Duration) *refCache[V] {
+	return &refCache[V]{
+		size:   size,
+		ttl:    ttl,
+		errTTL: errTTL,
+		items:  make(map[string]refCacheItem[V]),
+	}
+}
+
+// get returns the cached value, calling fetch if there is no valid cache item.
+func (c *refCache[V]) get(ref string, fetch func() (V, error)) (V, error) {
+	c.mu.Lock()
+	item, ok := c.items[ref]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(item.expires) {
+		return item.value, item.err
+	}
+
+	v, err, _ := c.sf.Do(ref, func() (any, error) {
+		v, err := fetch()
+		c.set(ref, v, err)
+		return v, err
+	})
+
+	res, _ := v.(V)
+
+	return res, err
+}
+
+func (c *refCache[V]) set(ref string, value V, err error) {
+	// A canceled request says nothing about the asset
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	ttl := c.ttl
+	if err != nil {
+		ttl = c.errTTL
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[ref]; !ok && len(c.items) >= c.size {
+		c.evict(now)
+	}
+
+	c.items[ref] = refCacheItem[V]{value: value, err: err, expires: now.Add(ttl)}
+}
+
+// evict removes expired items, or the one expiring first if none are expired. It must be called with mu locked.
+func (c *refCache[V]) evict(now time.Time) {
+	oldest := ""
+
+	for ref, item := range c.items {
+		if now.After(item.expires) {
+			delete(c.items, ref)
+			continue
+		}
+
+		if oldest == "" || item.expires.Before(c.items[oldest].expires) {
+			oldest = ref
+		}
+	}
+
+	if len(c.items) >= c.size && oldest != "" {
+		delete(c.items, oldest)
+	}
+}
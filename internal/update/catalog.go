@@ -0,0 +1,247 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultRefreshInterval = 5 * time.Minute
+	defaultMaxAge          = 15 * time.Minute
+
+	// refreshTimeout limits the time of loading releases of an app, including checksums and manifests.
+	refreshTimeout = time.Minute
+)
+
+// snapshot is the last successfully loaded state of an app's releases.
+type snapshot struct {
+	rels      []catalogRelease
+	updatedAt time.Time
+}
+
+// catalogRelease is a source release with checksums, the manifest and asset signatures resolved.
+type catalogRelease struct {
+	SourceRelease
+	manifest *Manifest
+	sigs     map[string]string
+}
+
+// Run refreshes snapshots of all the known apps and reloads the policy file in background until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	t := time.NewTicker(s.refreshInterval)
+	defer t.Stop()
+
+	pt := time.NewTicker(policyCheckInterval)
+	defer pt.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pt.C:
+			if s.policyFile == "" {
+				continue
+			}
+			if ok, err := s.reloadPolicy(); err != nil {
+				s.l.Error().Err(err).Str("path", s.policyFile).Msg("failed to reload policy")
+			} else if ok {
+				s.l.Info().Str("path", s.policyFile).Msg("policy reloaded")
+			}
+		case <-t.C:
+			for _, app := range s.apps() {
+				owner, name, _ := strings.Cut(app, "/")
+				if !s.policy.Load().known(owner, name) {
+					continue
+				}
+				if _, err := s.sharedRefresh(ctx, owner, name); errors.Is(err, ErrRateLimited) {
+					s.l.Warn().Err(err).Str("repo", app).Msg("releases refresh postponed")
+				} else if err != nil {
+					s.l.Error().Err(err).Str("repo", app).Msg("failed to refresh releases")
+				}
+			}
+		}
+	}
+}
+
+// releases returns a snapshot of the app's releases.
+//
+// The snapshot is served from memory unless it is older than the max age. A stale snapshot is refreshed
+// synchronously, once for all the concurrent requests, and it is still served if the refresh fails.
+func (s *Service) releases(ctx context.Context, owner, name string) ([]catalogRelease, error) {
+	app := owner + "/" + name
+
+	s.mu.RLock()
+	snap, ok := s.snapshots[app]
+	s.mu.RUnlock()
+
+	if ok && time.Since(snap.updatedAt) <= s.maxAge {
+		return snap.rels, nil
+	}
+
+	// The refresh is shared by requests, so it must not be canceled with one of them
+	fresh, err := s.sharedRefresh(context.WithoutCancel(ctx), owner, name)
+	if err != nil && ok {
+		s.l.Warn().Err(err).
+			Str("repo", app).
+			Dur("age", time.Since(snap.updatedAt)).
+			Msg("serving stale releases snapshot")
+		return snap.rels, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return fresh.rels, nil
+}
+
+// Released reports whether the app has a release of the version, on any channel.
+func (s *Service) Released(ctx context.Context, owner, name string, ver *semver.Version) (bool, error) {
+	if !s.policy.Load().known(owner, name) {
+		return false, ErrAppNotFound
+	}
+
+	rels, err := s.releases(ctx, owner, name)
+	if err != nil {
+		return false, err
+	}
+
+	for _, rel := range rels {
+		if relVer, err := semver.NewVersion(rel.Tag); err == nil && relVer.Equal(ver) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Refresh reloads releases of the app from its source, e.g. when a release is published.
+func (s *Service) Refresh(ctx context.Context, owner, name string) error {
+	if !s.policy.Load().known(owner, name) {
+		return ErrAppNotFound
+	}
+
+	// A refresh in progress may have started before the release event, so it is not joined
+	_, err := s.refresh(ctx, owner, name)
+	return err
+}
+
+// sharedRefresh refreshes the app snapshot, or waits for the refresh which is in progress.
+func (s *Service) sharedRefresh(ctx context.Context, owner, name string) (*snapshot, error) {
+	v, err, _ := s.refreshSF.Do(owner+"/"+name, func() (any, error) {
+		return s.refresh(ctx, owner, name)
+	})
+
+	snap, _ := v.(*snapshot)
+
+	return snap, err
+}
+
+func (s *Service) refresh(ctx context.Context, owner, name string) (*snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	src := s.source(owner, name)
+
+	srcRels, err := src.Releases(ctx, owner, name)
+	if err != nil {
+		return nil, fmt.Errorf("list releases: %w", err)
+	}
+
+	// Sources may share returned data between calls, so checksums are set on copies
+	rels := make([]catalogRelease, len(srcRels))
+
+	eg := errgroup.Group{}
+	eg.SetLimit(checksumFetchLimit)
+
+	for i, rel := range srcRels {
+		rels[i].SourceRelease = rel
+		rels[i].Assets = slices.Clone(rel.Assets)
+		rels[i].manifest = s.releaseManifest(ctx, src, rel)
+
+		if s.verifier != nil {
+			rels[i].sigs = s.releaseSignatures(ctx, src, rel)
+		}
+
+		for j, ast := range rel.Assets {
+			if isChecksumAsset(ast.Name) || strings.HasSuffix(ast.Name, sigAssetSuffix) {
+				continue
+			}
+
+			if ast.SHA256 == "" && !rels[i].manifest.hasChecksum(ast.Name) {
+				eg.Go(func() error {
+					rels[i].Assets[j].SHA256 = s.assetChecksum(ctx, src, rel, ast, checksumSHA256)
+					return nil
+				})
+			}
+
+			if ast.SHA512 == "" {
+				eg.Go(func() error {
+					rels[i].Assets[j].SHA512 = s.assetChecksum(ctx, src, rel, ast, checksumSHA512)
+					return nil
+				})
+			}
+		}
+	}
+
+	_ = eg.Wait() // checksum errors are logged and do not fail the refresh
+
+	snap := &snapshot{
+		rels:      rels,
+		updatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.snapshots[owner+"/"+name] = snap
+	s.mu.Unlock()
+
+	return snap, nil
+}
+
+func (s *Service) apps() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]string, 0, len(s.snapshots))
+	for app := range s.snapshots {
+		res = append(res, app)
+	}
+
+	return res
+}
+
+var snapshotAgeDesc = prometheus.NewDesc(
+	"d5y_cloud_update_snapshot_age_seconds",
+	"Age of the app releases snapshot",
+	[]string{"app"},
+	nil,
+)
+
+// snapshotAgeCollector exposes snapshot ages as of the scrape time.
+type snapshotAgeCollector struct {
+	s *Service
+}
+
+func (c snapshotAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- snapshotAgeDesc
+}
+
+func (c snapshotAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.s.mu.RLock()
+	defer c.s.mu.RUnlock()
+
+	for app, snap := range c.s.snapshots {
+		ch <- prometheus.MustNewConstMetric(
+			snapshotAgeDesc,
+			prometheus.GaugeValue,
+			time.Since(snap.updatedAt).Seconds(),
+			app,
+		)
+	}
+}
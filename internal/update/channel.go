@@ -0,0 +1,83 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Channel is an update channel. Channels are ordered from the most to the least stable one.
+type Channel string
+
+const (
+	ChannelStable  Channel = "stable"
+	ChannelBeta    Channel = "beta"
+	ChannelAlpha   Channel = "alpha"
+	ChannelNightly Channel = "nightly"
+)
+
+var channels = []Channel{ChannelStable, ChannelBeta, ChannelAlpha, ChannelNightly}
+
+// ChannelPolicy configures an update channel of an app.
+type ChannelPolicy struct {
+	// Fallback controls whether devices on the channel get releases of more stable channels; true if omitted.
+	Fallback *bool `yaml:"fallback"`
+}
+
+func ParseChannel(s string) (Channel, error) {
+	for _, ch := range channels {
+		if string(ch) == s {
+			return ch, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown channel: %s", s)
+}
+
+func (c Channel) rank() int {
+	for i, ch := range channels {
+		if ch == c {
+			return i
+		}
+	}
+
+	return len(channels)
+}
+
+// releaseChannel determines the channel of a release.
+//
+// Versions with `alpha` prerelease identifiers go to the alpha channel, `beta` and `rc` ones go to the beta channel,
+// other prereleases and drafts go to the nightly channel. Releases flagged as prereleases by the source go to the beta
+// channel unless their versions say otherwise.
+func releaseChannel(ver *semver.Version, rel SourceRelease) Channel {
+	pre := strings.ToLower(ver.Prerelease())
+
+	switch {
+	case rel.Draft:
+		return ChannelNightly
+	case strings.HasPrefix(pre, "alpha"):
+		return ChannelAlpha
+	case strings.HasPrefix(pre, "beta"), strings.HasPrefix(pre, "rc"):
+		return ChannelBeta
+	case pre != "":
+		return ChannelNightly
+	case rel.Prerelease:
+		return ChannelBeta
+	default:
+		return ChannelStable
+	}
+}
+
+// channelAllows reports whether devices on the device channel get releases of the release channel.
+func (p AppPolicy) channelAllows(device, release Channel) bool {
+	if device == release {
+		return true
+	}
+
+	if fb := p.Channels[device].Fallback; fb != nil && !*fb {
+		return false
+	}
+
+	return release.rank() < device.rank()
+}
@@ -0,0 +1,79 @@
+package update
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/clientinfo"
+)
+
+func TestReleaseChannel(t *testing.T) {
+	tests := []struct {
+		version string
+		release SourceRelease
+		want    Channel
+	}{
+		{version: "1.0.0", want: ChannelStable},
+		{version: "1.0.0", release: SourceRelease{Prerelease: true}, want: ChannelBeta},
+		{version: "1.0.0", release: SourceRelease{Draft: true}, want: ChannelNightly},
+		{version: "1.0.0-beta1", want: ChannelBeta},
+		{version: "1.0.0-RC.2", want: ChannelBeta},
+		{version: "1.0.0-alpha3", release: SourceRelease{Prerelease: true}, want: ChannelAlpha},
+		{version: "1.0.0-dev.20260101", want: ChannelNightly},
+	}
+
+	for _, tt := range tests {
+		if got := releaseChannel(semver.MustParse(tt.version), tt.release); got != tt.want {
+			t.Errorf("%s %+v: got %s, want %s", tt.version, tt.release, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseSetNextChannels(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		channel Channel
+		current string
+		want    string
+	}{
+		{name: "stable", channel: ChannelStable, current: "2.0.0"},
+		{name: "beta", channel: ChannelBeta, current: "2.0.0", want: "2.1.0-beta1"},
+		{name: "alpha", channel: ChannelAlpha, current: "2.0.0", want: "2.1.0-alpha1"},
+		{name: "nightly", channel: ChannelNightly, current: "2.0.0", want: "2.1.0-alpha1"},
+		{name: "beta with fallback", channel: ChannelBeta, current: "1.0.0", want: "1.1.0"},
+		{
+			name:    "beta without fallback",
+			policy:  "    channels: {beta: {fallback: false}}\n",
+			channel: ChannelBeta,
+			current: "1.0.0",
+			want:    "2.1.0-beta1",
+		},
+		{
+			name:    "assigned device channel",
+			policy:  "    device_channels: {dev1: alpha}\n",
+			channel: ChannelStable,
+			current: "2.0.0",
+			want:    "2.1.0-alpha1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, tt.policy, Config{})
+
+			// The device channel is resolved before releases are listed, as handlers do
+			ch := svc.Channel("ashep", "cronus", "dev1", tt.channel)
+
+			rlsSet, err := svc.List(context.Background(), "ashep", "cronus", "esp32", ch)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := nextVersion(rlsSet, tt.current, clientinfo.Info{ID: "dev1"}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
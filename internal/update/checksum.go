@@ -0,0 +1,183 @@
+package update
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	checksumCacheSize     = 4096
+	checksumCacheTTL      = 24 * time.Hour
+	checksumCacheErrorTTL = time.Minute
+	checksumFetchLimit    = 8
+	maxChecksumsSize      = 64 << 10
+)
+
+var errChecksumNotFound = errors.New("checksum not found")
+
+// checksumAlgo describes where checksums of an algorithm are published.
+type checksumAlgo struct {
+	sidecarSuffix string // `{asset}{suffix}` file with the checksum of the asset
+	sumsName      string // release asset with checksums of all the assets
+	hexLen        int
+}
+
+var (
+	checksumSHA256 = checksumAlgo{sidecarSuffix: ".sha256", sumsName: "SHA256SUMS", hexLen: 64}
+	checksumSHA512 = checksumAlgo{sidecarSuffix: ".sha512", sumsName: "SHA512SUMS", hexLen: 128}
+)
+
+// isChecksumAsset reports whether the asset contains checksums of other assets.
+func isChecksumAsset(name string) bool {
+	for _, algo := range []checksumAlgo{checksumSHA256, checksumSHA512} {
+		if strings.HasSuffix(name, algo.sidecarSuffix) || name == algo.sumsName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validChecksum reports whether s is a lowercase hex encoded checksum of the algorithm.
+func (a checksumAlgo) validChecksum(s string) bool {
+	if len(s) != a.hexLen {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+
+	return err == nil && strings.ToLower(s) == s
+}
+
+// parse finds the checksum of the asset in the sidecar or sums file content in `sha256sum` format, i.e. lines of
+// `{hash}  {name}` or `{hash} *{name}`. A sidecar may contain just the hash; its first line is used regardless of
+// the file name.
+func (a checksumAlgo) parse(content, assetName string, sidecar bool) (string, error) {
+	for line := range strings.Lines(content) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if !sidecar && (len(fields) < 2 || path.Base(strings.TrimPrefix(fields[1], "*")) != assetName) {
+			continue
+		}
+
+		sum := strings.ToLower(fields[0])
+		if !a.validChecksum(sum) {
+			return "", fmt.Errorf("invalid checksum: %q", fields[0])
+		}
+
+		return sum, nil
+	}
+
+	return "", errChecksumNotFound
+}
+
+// assetChecksum returns the checksum of ast read from its sidecar asset, or from the sums asset of rel.
+// Nothing is returned if the release publishes no checksums of the algorithm.
+func (s *Service) assetChecksum(
+	ctx context.Context,
+	src ReleaseSource,
+	rel SourceRelease,
+	ast SourceAsset,
+	algo checksumAlgo,
+) string {
+	sidecar := true
+
+	idx := slices.IndexFunc(rel.Assets, func(a SourceAsset) bool {
+		return a.Name == ast.Name+algo.sidecarSuffix
+	})
+	if idx < 0 {
+		sidecar = false
+		idx = slices.IndexFunc(rel.Assets, func(a SourceAsset) bool {
+			return a.Name == algo.sumsName
+		})
+	}
+
+	if idx < 0 {
+		return ""
+	}
+
+	sumAst := rel.Assets[idx]
+
+	content, err := s.checksums.get(sumAst.Ref, func() (string, error) {
+		return fetchChecksums(ctx, src, sumAst)
+	})
+	if err != nil {
+		s.l.Error().Err(err).Str("ref", sumAst.Ref).Msg("failed to fetch asset checksum")
+		return ""
+	}
+
+	sum, err := algo.parse(content, ast.Name, sidecar)
+	if !sidecar && errors.Is(err, errChecksumNotFound) {
+		// Sums files usually do not cover auxiliary assets
+		return ""
+	} else if err != nil {
+		s.l.Error().Err(err).
+			Str("tag_name", rel.Tag).
+			Str("asset_name", ast.Name).
+			Str("ref", sumAst.Ref).
+			Msg("failed to read asset checksum")
+		return ""
+	}
+
+	return sum
+}
+
+func fetchChecksums(ctx context.Context, src ReleaseSource, ast SourceAsset) (string, error) {
+	rc, err := src.Fetch(ctx, ast)
+	if err != nil {
+		return "", fmt.Errorf("fetch: %w", err)
+	}
+
+	defer rc.Close() //nolint:errcheck // ok
+
+	b, err := io.ReadAll(io.LimitReader(rc, maxChecksumsSize))
+	if err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// checkedAssets drops malformed checksums of the assets. In strict mode assets without valid SHA256 checksums are
+// removed, as v1 clients verify SHA256 only.
+func (s *Service) checkedAssets(tag string, assets []Asset) []Asset {
+	for i := range assets {
+		ast := &assets[i]
+
+		ast.SHA256 = strings.ToLower(ast.SHA256)
+		if ast.SHA256 != "" && !checksumSHA256.validChecksum(ast.SHA256) {
+			s.l.Warn().Str("tag_name", tag).Str("asset_name", ast.Name).Msg("invalid asset sha256 checksum")
+			ast.SHA256 = ""
+		}
+
+		ast.SHA512 = strings.ToLower(ast.SHA512)
+		if ast.SHA512 != "" && !checksumSHA512.validChecksum(ast.SHA512) {
+			s.l.Warn().Str("tag_name", tag).Str("asset_name", ast.Name).Msg("invalid asset sha512 checksum")
+			ast.SHA512 = ""
+		}
+	}
+
+	if !s.strictChecksums {
+		return assets
+	}
+
+	return slices.DeleteFunc(assets, func(ast Asset) bool {
+		if ast.SHA256 != "" {
+			return false
+		}
+
+		s.l.Warn().Str("tag_name", tag).Str("asset_name", ast.Name).Msg("skip asset: no valid sha256 checksum")
+
+		return true
+	})
+}
@@ -0,0 +1,395 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// memSource is a ReleaseSource keeping releases and asset contents in memory.
+type memSource struct {
+	rels     map[string][]SourceRelease // keyed by `{owner}/{name}`
+	contents map[string]string          // keyed by asset refs
+	delay    time.Duration              // delay of Releases calls
+
+	releasesCalls atomic.Int32
+	mu            sync.Mutex
+	fetchCalls    map[string]int
+}
+
+func (s *memSource) Releases(_ context.Context, owner, name string) ([]SourceRelease, error) {
+	s.releasesCalls.Add(1)
+	time.Sleep(s.delay)
+
+	rels, ok := s.rels[owner+"/"+name]
+	if !ok {
+		return nil, ErrAppNotFound
+	}
+
+	return rels, nil
+}
+
+func (s *memSource) Fetch(_ context.Context, ast SourceAsset) (io.ReadCloser, error) {
+	s.mu.Lock()
+	if s.fetchCalls == nil {
+		s.fetchCalls = make(map[string]int)
+	}
+	s.fetchCalls[ast.Ref]++
+	s.mu.Unlock()
+
+	content, ok := s.contents[ast.Ref]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func (s *memSource) fetches(ref string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.fetchCalls[ref]
+}
+
+func TestChecksumCacheTTL(t *testing.T) {
+	c := newRefCache[string](10, 50*time.Millisecond, time.Hour)
+
+	calls := 0
+	fetch := func() (string, error) {
+		calls++
+		return "sum", nil
+	}
+
+	for range 3 {
+		if v, err := c.get("a", fetch); err != nil || v != "sum" {
+			t.Fatalf("got %q, %v", v, err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("got %d fetches before expiration, want 1", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := c.get("a", fetch); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("got %d fetches after expiration, want 2", calls)
+	}
+}
+
+func TestChecksumCacheErrorTTL(t *testing.T) {
+	c := newRefCache[string](10, time.Hour, 50*time.Millisecond)
+
+	errFetch := errors.New("fetch failed")
+
+	calls := 0
+	fetch := func() (string, error) {
+		calls++
+		return "", errFetch
+	}
+
+	for range 3 {
+		if _, err := c.get("a", fetch); !errors.Is(err, errFetch) {
+			t.Fatalf("got %v, want %v", err, errFetch)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("got %d fetches before error expiration, want 1", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := c.get("a", fetch); !errors.Is(err, errFetch) {
+		t.Fatalf("got %v, want %v", err, errFetch)
+	}
+
+	if calls != 2 {
+		t.Fatalf("got %d fetches after error expiration, want 2", calls)
+	}
+}
+
+func TestChecksumCacheContextErrorNotCached(t *testing.T) {
+	c := newRefCache[string](10, time.Hour, time.Hour)
+
+	calls := 0
+	fetch := func() (string, error) {
+		calls++
+		return "", context.Canceled
+	}
+
+	for range 2 {
+		if _, err := c.get("a", fetch); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want %v", err, context.Canceled)
+		}
+	}
+
+	if calls != 2 {
+		t.Fatalf("got %d fetches, want 2", calls)
+	}
+}
+
+func TestChecksumCacheEviction(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		items map[string]time.Time // expiration times
+		want  []string
+	}{
+		{
+			name:  "expiring first",
+			items: map[string]time.Time{"a": now.Add(time.Hour), "b": now.Add(time.Minute)},
+			want:  []string{"a", "c"},
+		},
+		{
+			name:  "expired",
+			items: map[string]time.Time{"a": now.Add(-time.Minute), "b": now.Add(time.Minute)},
+			want:  []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newRefCache[string](2, time.Hour, time.Hour)
+
+			for ref, exp := range tt.items {
+				c.items[ref] = refCacheItem[string]{value: ref, expires: exp}
+			}
+
+			c.set("c", "c", nil)
+
+			if len(c.items) != len(tt.want) {
+				t.Fatalf("got %d items, want %d", len(c.items), len(tt.want))
+			}
+
+			for _, ref := range tt.want {
+				if _, ok := c.items[ref]; !ok {
+					t.Errorf("item %s is evicted", ref)
+				}
+			}
+		})
+	}
+}
+
+func TestChecksumCacheDeduplication(t *testing.T) {
+	c := newRefCache[string](10, time.Hour, time.Hour)
+
+	calls := atomic.Int32{}
+	fetch := func() (string, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "sum", nil
+	}
+
+	wg := sync.WaitGroup{}
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.get("a", fetch); err != nil || v != "sum" {
+				t.Errorf("got %q, %v", v, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("got %d fetches, want 1", n)
+	}
+}
+
+func TestServiceListConcurrent(t *testing.T) {
+	const (
+		sha256App  = "1111111111111111111111111111111111111111111111111111111111111111"
+		sha256Boot = "2222222222222222222222222222222222222222222222222222222222222222"
+	)
+
+	src := &memSource{
+		rels: map[string][]SourceRelease{
+			"ashep/cronus": {
+				{
+					Tag: "1.0.0",
+					Assets: []SourceAsset{
+						{Name: "cronus-esp32.bin", Ref: "1.0.0/cronus-esp32.bin"},
+						{Name: "cronus-esp32-bootloader.bin", Ref: "1.0.0/cronus-esp32-bootloader.bin"},
+						{Name: "cronus-esp32.bin.sha256", Ref: "1.0.0/cronus-esp32.bin.sha256"},
+						{Name: "SHA256SUMS", Ref: "1.0.0/SHA256SUMS"},
+					},
+				},
+			},
+		},
+		contents: map[string]string{
+			"1.0.0/cronus-esp32.bin.sha256": sha256App + "\n",
+			"1.0.0/SHA256SUMS":              sha256App + "  cronus-esp32.bin\n" + sha256Boot + "  cronus-esp32-bootloader.bin\n",
+		},
+		delay: 10 * time.Millisecond,
+	}
+
+	svc, err := New(src, nil, Config{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rlsSet, err := svc.List(context.Background(), "ashep", "cronus", "esp32", ChannelStable)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if len(rlsSet.List) != 1 || len(rlsSet.List[0].Assets) != 2 {
+				t.Errorf("unexpected releases: %+v", rlsSet.List)
+				return
+			}
+
+			for _, ast := range rlsSet.List[0].Assets {
+				want := sha256App
+				if ast.Role == AssetRoleBootloader {
+					want = sha256Boot
+				}
+
+				if ast.SHA256 != want {
+					t.Errorf("%s: got sha256 %q, want %q", ast.Name, ast.SHA256, want)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := src.releasesCalls.Load(); n != 1 {
+		t.Errorf("got %d releases calls, want 1", n)
+	}
+
+	for _, ref := range []string{"1.0.0/cronus-esp32.bin.sha256", "1.0.0/SHA256SUMS"} {
+		if n := src.fetches(ref); n != 1 {
+			t.Errorf("%s: got %d fetches, want 1", ref, n)
+		}
+	}
+}
+
+func TestChecksumParse(t *testing.T) {
+	const (
+		sum  = "1111111111111111111111111111111111111111111111111111111111111111"
+		sum2 = "2222222222222222222222222222222222222222222222222222222222222222"
+	)
+
+	tests := []struct {
+		name    string
+		content string
+		sidecar bool
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "sidecar hash",
+			content: sum + "\n",
+			sidecar: true,
+			want:    sum,
+		},
+		{
+			name:    "sidecar with another name",
+			content: sum + "  firmware.bin\n",
+			sidecar: true,
+			want:    sum,
+		},
+		{
+			name:    "sums",
+			content: sum2 + "  cronus-esp32-bootloader.bin\n" + sum + "  cronus-esp32.bin\n",
+			want:    sum,
+		},
+		{
+			name:    "sums in binary mode",
+			content: sum + " *build/cronus-esp32.bin\n",
+			want:    sum,
+		},
+		{
+			name:    "uppercase hash",
+			content: strings.ToUpper(sum) + "  cronus-esp32.bin\n",
+			want:    sum,
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# checksums\n\n" + sum + "  cronus-esp32.bin\n",
+			want:    sum,
+		},
+		{
+			name:    "missing asset",
+			content: sum2 + "  cronus-esp32-bootloader.bin\n",
+			wantErr: errChecksumNotFound.Error(),
+		},
+		{
+			name:    "empty",
+			sidecar: true,
+			wantErr: errChecksumNotFound.Error(),
+		},
+		{
+			name:    "malformed hash",
+			content: "xyz  cronus-esp32.bin\n",
+			wantErr: "invalid checksum",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checksumSHA256.parse(tt.content, "cronus-esp32.bin", tt.sidecar)
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckedAssetsStrict(t *testing.T) {
+	const (
+		sum256 = "1111111111111111111111111111111111111111111111111111111111111111"
+		sum512 = sum256 + sum256
+	)
+
+	s := &Service{strictChecksums: true, l: zerolog.Nop()}
+
+	got := s.checkedAssets("1.0.0", []Asset{
+		{Name: "sha256.bin", SHA256: sum256},
+		{Name: "both.bin", SHA256: sum256, SHA512: sum512},
+		{Name: "sha512.bin", SHA512: sum512},
+		{Name: "malformed.bin", SHA256: "xyz", SHA512: sum512},
+		{Name: "none.bin"},
+	})
+
+	names := make([]string, 0, len(got))
+	for _, ast := range got {
+		names = append(names, ast.Name)
+	}
+
+	if want := []string{"sha256.bin", "both.bin"}; !slices.Equal(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
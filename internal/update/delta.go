@@ -0,0 +1,133 @@
+package update
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	deltaAssetInfix  = ".from-"
+	deltaAssetSuffix = ".patch"
+)
+
+// Delta is a binary patch which turns the app image of the base version into the app image of a release.
+//
+// Deltas are published as `{asset}.from-{version}.patch` release assets, e.g. `cronus-esp32.bin.from-1.2.0.patch`
+// patches the `cronus-esp32.bin` asset of the 1.2.0 release.
+type Delta struct {
+	Asset
+	Base         string `json:"base"`          // version the delta applies to
+	BaseSHA256   string `json:"base_sha256"`   // checksum of the app image of the base version
+	ResultSHA256 string `json:"result_sha256"` // checksum of the app image the delta produces
+
+	target string
+}
+
+// parseDeltaAssetName splits the name of a delta asset into the name of the target asset and the base version.
+func parseDeltaAssetName(name string) (string, *semver.Version, bool) {
+	if !strings.HasSuffix(name, deltaAssetSuffix) {
+		return "", nil, false
+	}
+
+	target, base, ok := cutLast(strings.TrimSuffix(name, deltaAssetSuffix), deltaAssetInfix)
+	if !ok {
+		return "", nil, false
+	}
+
+	ver, err := semver.NewVersion(base)
+	if err != nil {
+		return "", nil, false
+	}
+
+	return target, ver, true
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
+
+// releaseDeltas returns the deltas of the release which patch the given assets.
+// Deltas are verified and served the same way as the assets.
+func (s *Service) releaseDeltas(owner, name string, src ReleaseSource, rel catalogRelease, assets []Asset) []Delta {
+	res := make([]Delta, 0)
+
+	for _, ast := range rel.Assets {
+		target, base, ok := parseDeltaAssetName(ast.Name)
+		if !ok {
+			continue
+		}
+
+		idx := slices.IndexFunc(assets, func(a Asset) bool { return a.Name == target })
+		if idx < 0 {
+			continue
+		}
+
+		dAsts := s.checkedAssets(rel.Tag, []Asset{{
+			Name:   ast.Name,
+			Role:   assets[idx].Role,
+			Size:   ast.Size,
+			SHA256: ast.SHA256,
+			SHA512: ast.SHA512,
+			URL:    ast.URL,
+		}})
+		dAsts = s.verifiedAssets(rel, dAsts)
+		if len(dAsts) == 0 {
+			continue
+		}
+
+		res = append(res, Delta{
+			Asset:        s.servedAssets(owner, name, src, rel.SourceRelease, dAsts)[0],
+			Base:         base.String(),
+			ResultSHA256: assets[idx].SHA256,
+			target:       target,
+		})
+	}
+
+	return res
+}
+
+// Delta returns the delta which updates the device running the current version to the release.
+//
+// Nil is returned if there is no delta for the current version, if it is not smaller than the full app image, or if
+// checksums of the base or the resulting images are unknown.
+func (r ReleaseSet) Delta(rel *Release, current *semver.Version) *Delta {
+	if rel == nil || current == nil {
+		return nil
+	}
+
+	target := rel.AppAsset()
+	if target == nil || target.SHA256 == "" {
+		return nil
+	}
+
+	var base *Asset
+	for i := range r.List {
+		if r.List[i].Version.Equal(current) {
+			base = r.List[i].AppAsset()
+			break
+		}
+	}
+
+	if base == nil || base.SHA256 == "" {
+		return nil
+	}
+
+	for _, d := range rel.Deltas {
+		if d.target != target.Name || d.Base != current.String() || d.SHA256 == "" || d.Size >= target.Size {
+			continue
+		}
+
+		d.BaseSHA256 = base.SHA256
+
+		return &d
+	}
+
+	return nil
+}
@@ -4,4 +4,7 @@ import (
 	"errors"
 )
 
-var ErrAppNotFound = errors.New("app not found")
+var (
+	ErrAppNotFound = errors.New("app not found")
+	ErrRateLimited = errors.New("rate limited")
+)
@@ -1,75 +1,58 @@
 package update
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/Masterminds/semver/v3"
 )
 
-type excludeItem struct {
-	Owner   string
-	Name    string
-	Version *semver.Version
+// Exclusion forbids updates from or to versions matching a semver constraint.
+//
+// Note that prerelease versions match only constraints which contain prereleases, and prerelease identifiers are
+// compared lexically, so `>=1.0.0-alpha1 <=1.0.0-alpha5` matches `1.0.0-alpha10` too; list exact prereleases joined by
+// `||` instead.
+type Exclusion struct {
+	Versions string `yaml:"versions"` // semver constraint, e.g. `>=1.2.0 <1.2.3`
+	Reason   string `yaml:"reason"`
+
+	constraints *semver.Constraints
 }
 
-type excludeList []excludeItem
+func (e *Exclusion) compile() error {
+	if e.Reason == "" {
+		return errors.New("empty reason")
+	}
 
-func (e excludeList) Contains(owner, name string, version *semver.Version) bool {
+	c, err := semver.NewConstraint(e.Versions)
+	if err != nil {
+		return fmt.Errorf("invalid versions: %w", err)
+	}
+
+	e.constraints = c
+
+	return nil
+}
+
+type exclusionList []Exclusion
+
+// Match returns the first exclusion matching the version.
+func (e exclusionList) Match(version *semver.Version) (Exclusion, bool) {
 	for _, item := range e {
-		if item.Owner == owner && item.Name == name && item.Version.Equal(version) {
-			return true
+		if item.constraints != nil && item.constraints.Check(version) {
+			return item, true
 		}
 	}
-	return false
-}
 
-// excVerSrc contains versions that cannot be upgraded from.
-var excVerSrc = excludeList{
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha1"),
-	},
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha2"),
-	},
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha3"),
-	},
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha4"),
-	},
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha5"),
-	},
+	return Exclusion{}, false
 }
 
-// excVerDst contains versions that cannot be upgraded to.
-var excVerDst = excludeList{
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha2"),
-	},
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha3"),
-	},
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha4"),
-	},
-	{
-		Owner:   "ashep",
-		Name:    "cronus",
-		Version: semver.MustParse("0.0.1-alpha5"),
-	},
+func (e exclusionList) compile() error {
+	for i := range e {
+		if err := e[i].compile(); err != nil {
+			return fmt.Errorf("%d: %w", i, err)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,97 @@
+package update
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/clientinfo"
+)
+
+func TestReleaseSetExclusions(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		current  string
+		wantNext string
+		wantPath []string
+	}{
+		{
+			name:     "excluded to",
+			policy:   "    exclude_to: [{versions: '1.1.0', reason: broken}]\n",
+			current:  "1.0.0",
+			wantNext: "1.2.0",
+			wantPath: []string{"1.2.0", "2.0.0"},
+		},
+		{
+			name:     "excluded from",
+			policy:   "    exclude_from: [{versions: '<1.1.0', reason: no ota}]\n",
+			current:  "1.0.0",
+			wantPath: []string{},
+		},
+		{
+			name:     "excluded from on the path",
+			policy:   "    exclude_from: [{versions: '1.1.0', reason: no ota}]\n",
+			current:  "1.0.0",
+			wantNext: "1.1.0",
+			wantPath: []string{"1.1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rlsSet := newTestReleaseSet(t, tt.policy, Config{}, ChannelStable)
+
+			if got := nextVersion(rlsSet, tt.current, clientinfo.Info{}); got != tt.wantNext {
+				t.Errorf("next: got %q, want %q", got, tt.wantNext)
+			}
+
+			if got := pathVersions(rlsSet, tt.current, clientinfo.Info{}); !slices.Equal(got, tt.wantPath) {
+				t.Errorf("path: got %v, want %v", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestDefaultPolicyExclusions(t *testing.T) {
+	tests := []struct {
+		version      string
+		excludedFrom bool
+		excludedTo   bool
+	}{
+		{version: "0.0.1-alpha1", excludedFrom: true},
+		{version: "0.0.1-alpha2", excludedFrom: true, excludedTo: true},
+		{version: "0.0.1-alpha5", excludedFrom: true, excludedTo: true},
+		{version: "0.0.1-alpha6"},
+		{version: "0.0.1-alpha10"},
+		{version: "0.0.1-alpha2.1"},
+		{version: "0.0.1"},
+	}
+
+	for _, pol := range []*Policy{DefaultPolicy(), mustLoadPolicy(t, "../../policy.example.yaml")} {
+		appPol := pol.App("ashep", "cronus")
+
+		for _, tt := range tests {
+			ver := semver.MustParse(tt.version)
+
+			if _, ok := appPol.ExcludeFrom.Match(ver); ok != tt.excludedFrom {
+				t.Errorf("%s: got excluded from %v, want %v", tt.version, ok, tt.excludedFrom)
+			}
+
+			if _, ok := appPol.ExcludeTo.Match(ver); ok != tt.excludedTo {
+				t.Errorf("%s: got excluded to %v, want %v", tt.version, ok, tt.excludedTo)
+			}
+		}
+	}
+}
+
+func mustLoadPolicy(t *testing.T, path string) *Policy {
+	t.Helper()
+
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
@@ -0,0 +1,62 @@
+package update
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// assetIDs returns identifiers of assets compatible with the hardware.
+// Hardware which is not mapped by the app policy is compatible with assets identified by the hardware ID itself.
+func (p AppPolicy) assetIDs(hardware string) []string {
+	hardware = normalizeArch(hardware)
+
+	if ids, ok := p.Hardware[hardware]; ok {
+		return ids
+	}
+
+	return []string{hardware}
+}
+
+// matchAssetName reports whether the asset identifier in the asset name is exactly one of the identifiers.
+func matchAssetName(appName, assetName string, ids []string) bool {
+	id := assetNameID(appName, assetName)
+
+	return id != "" && slices.Contains(ids, id)
+}
+
+// versionTokenRe matches a version in an asset name, e.g. `1.2.0` or `v1.2.0-beta1`, followed by the extension.
+var versionTokenRe = regexp.MustCompile(`^v?\d+(\.\d+)+`)
+
+// assetNameID returns the normalized asset identifier of the asset name: the run of `-` and `_` separated tokens after
+// the app name, up to the file extension, a version or a role word.
+//
+// E.g. the identifier of `cronus-esp32.bin`, `cronus-esp32-v1.2.0.bin` and `cronus-esp32-bootloader.bin` is `esp32`,
+// and the identifier of `cronus-esp32-c3-4mb.bin` is `esp32_c3_4mb`, so the `esp32` identifier matches neither
+// `cronus-esp32-s3.bin` nor `cronus-esp32-4mb.bin`.
+func assetNameID(appName, assetName string) string {
+	name := strings.TrimPrefix(normalizeArch(assetName), normalizeArch(appName))
+
+	toks := make([]string, 0)
+	for _, tok := range strings.Split(name, "_") {
+		if versionTokenRe.MatchString(tok) {
+			break
+		}
+
+		tok, _, ext := strings.Cut(tok, ".")
+
+		if _, ok := tokenRole(tok); ok {
+			break
+		}
+
+		if tok != "" {
+			toks = append(toks, tok)
+		}
+
+		if ext {
+			break
+		}
+	}
+
+	return strings.Join(toks, "_")
+}
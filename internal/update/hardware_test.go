@@ -0,0 +1,103 @@
+package update
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatchAssetName(t *testing.T) {
+	assets := []string{
+		"cronus-esp32.bin",
+		"cronus-esp32-bootloader.bin",
+		"cronus-esp32-partitions.bin",
+		"cronus-esp32-4mb.bin",
+		"cronus-esp32-s3.bin",
+		"cronus-esp32-s3-littlefs.bin",
+		"cronus-esp32s3.bin",
+		"cronus-esp32-c3-4mb.bin",
+		"cronus_ESP32_C3_4MB.bin",
+		"cronus-esp32-v1.2.0.bin",
+		"cronus-esp32-1.2.0-beta1.bin",
+		"cronus-esp32-s3-v1.2.0.bin",
+	}
+
+	tests := []struct {
+		name     string
+		hardware string
+		policy   AppPolicy
+		want     []string
+	}{
+		{
+			name:     "esp32",
+			hardware: "esp32",
+			want: []string{
+				"cronus-esp32.bin",
+				"cronus-esp32-bootloader.bin",
+				"cronus-esp32-partitions.bin",
+				"cronus-esp32-v1.2.0.bin",
+				"cronus-esp32-1.2.0-beta1.bin",
+			},
+		},
+		{
+			name:     "esp32-s3",
+			hardware: "esp32-s3",
+			want:     []string{"cronus-esp32-s3.bin", "cronus-esp32-s3-littlefs.bin", "cronus-esp32-s3-v1.2.0.bin"},
+		},
+		{
+			name:     "esp32s3",
+			hardware: "esp32s3",
+			want:     []string{"cronus-esp32s3.bin"},
+		},
+		{
+			name:     "esp32-c3-4mb",
+			hardware: "ESP32-C3-4MB",
+			want:     []string{"cronus-esp32-c3-4mb.bin", "cronus_ESP32_C3_4MB.bin"},
+		},
+		{
+			name:     "esp32c3",
+			hardware: "esp32c3",
+			want:     []string{},
+		},
+		{
+			name:     "mapped esp32",
+			hardware: "esp32",
+			policy:   AppPolicy{Hardware: map[string][]string{"esp32": {"esp32", "esp32_4mb"}}},
+			want: []string{
+				"cronus-esp32.bin",
+				"cronus-esp32-bootloader.bin",
+				"cronus-esp32-partitions.bin",
+				"cronus-esp32-4mb.bin",
+				"cronus-esp32-v1.2.0.bin",
+				"cronus-esp32-1.2.0-beta1.bin",
+			},
+		},
+		{
+			name:     "mapped esp32s3",
+			hardware: "esp32s3",
+			policy:   AppPolicy{Hardware: map[string][]string{"esp32s3": {"esp32s3", "esp32_s3"}}},
+			want: []string{
+				"cronus-esp32-s3.bin",
+				"cronus-esp32-s3-littlefs.bin",
+				"cronus-esp32s3.bin",
+				"cronus-esp32-s3-v1.2.0.bin",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := tt.policy.assetIDs(tt.hardware)
+
+			got := make([]string, 0)
+			for _, ast := range assets {
+				if matchAssetName("cronus", ast, ids) {
+					got = append(got, ast)
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	manifestAssetName     = "manifest.json"
+	maxManifestSize       = 1 << 20
+	manifestCacheSize     = 1024
+	manifestCacheTTL      = time.Hour
+	manifestCacheErrorTTL = time.Minute
+)
+
+// Manifest is an optional release asset describing the release and its assets.
+type Manifest struct {
+	Notes          string            `json:"notes"`           // overrides notes of the source release
+	LocalizedNotes map[string]string `json:"localized_notes"` // keyed by language tags, e.g. `uk` or `pt-BR`
+	Critical       bool              `json:"critical"`
+	Assets         []ManifestAsset   `json:"assets"`
+}
+
+type ManifestAsset struct {
+	Name          string    `json:"name"`
+	Role          AssetRole `json:"role"`           // app if omitted
+	Hardware      []string  `json:"hardware"`       // compatible hardware IDs or asset identifiers
+	MinBootloader string    `json:"min_bootloader"` // minimum bootloader version required by the asset
+	Size          int       `json:"size"`
+	SHA256        string    `json:"sha256"`
+}
+
+func (m *Manifest) validate() error {
+	for i, ast := range m.Assets {
+		if ast.Name == "" {
+			return fmt.Errorf("asset %d: empty name", i)
+		}
+
+		switch ast.Role {
+		case "", AssetRoleApp, AssetRoleBootloader, AssetRolePartitionTable, AssetRoleFilesystem:
+		default:
+			return fmt.Errorf("asset %s: unknown role: %s", ast.Name, ast.Role)
+		}
+
+		if ast.MinBootloader != "" {
+			if _, err := semver.NewVersion(ast.MinBootloader); err != nil {
+				return fmt.Errorf("asset %s: invalid min bootloader version: %w", ast.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasChecksum reports whether the manifest declares the checksum of the asset.
+func (m *Manifest) hasChecksum(name string) bool {
+	if m == nil {
+		return false
+	}
+
+	return slices.ContainsFunc(m.Assets, func(a ManifestAsset) bool {
+		return a.Name == name && a.SHA256 != ""
+	})
+}
+
+// releaseManifest fetches and parses the manifest of the release.
+// Nothing is returned if the release has no manifest or the manifest is invalid.
+func (s *Service) releaseManifest(ctx context.Context, src ReleaseSource, rel SourceRelease) *Manifest {
+	idx := slices.IndexFunc(rel.Assets, func(a SourceAsset) bool {
+		return a.Name == manifestAssetName
+	})
+	if idx < 0 {
+		return nil
+	}
+
+	mAst := rel.Assets[idx]
+
+	m, err := s.manifests.get(mAst.Ref, func() (*Manifest, error) {
+		return fetchManifest(ctx, src, mAst)
+	})
+	if err != nil {
+		s.l.Error().Err(err).Str("tag_name", rel.Tag).Str("ref", mAst.Ref).Msg("failed to get release manifest")
+		return nil
+	}
+
+	return m
+}
+
+func fetchManifest(ctx context.Context, src ReleaseSource, ast SourceAsset) (*Manifest, error) {
+	rc, err := src.Fetch(ctx, ast)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %w", err)
+	}
+
+	defer rc.Close() //nolint:errcheck // ok
+
+	b, err := io.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// manifestAssets returns the assets which declared hardware is the hardware ID of the device, or one of the asset
+// identifiers mapped to it by the app policy.
+func (s *Service) manifestAssets(m *Manifest, rel SourceRelease, hardware string, astIDs []string) []Asset {
+	res := make([]Asset, 0)
+
+	for _, mAst := range m.Assets {
+		if !slices.ContainsFunc(mAst.Hardware, func(hw string) bool {
+			hw = normalizeArch(hw)
+			return hw == hardware || slices.Contains(astIDs, hw)
+		}) {
+			continue
+		}
+
+		idx := slices.IndexFunc(rel.Assets, func(a SourceAsset) bool {
+			return a.Name == mAst.Name
+		})
+		if idx < 0 {
+			s.l.Warn().
+				Str("tag_name", rel.Tag).
+				Str("asset_name", mAst.Name).
+				Msg("skip asset: declared in the manifest, but not found in the release")
+			continue
+		}
+
+		srcAst := rel.Assets[idx]
+
+		ast := Asset{
+			Name:          mAst.Name,
+			Role:          mAst.Role,
+			Size:          mAst.Size,
+			SHA256:        strings.ToLower(mAst.SHA256),
+			URL:           srcAst.URL,
+			MinBootloader: mAst.MinBootloader,
+		}
+
+		if ast.Role == "" {
+			ast.Role = AssetRoleApp
+		}
+
+		if ast.Size == 0 {
+			ast.Size = srcAst.Size
+		}
+
+		if ast.SHA256 == "" {
+			ast.SHA256 = srcAst.SHA256
+		}
+
+		ast.SHA512 = srcAst.SHA512
+
+		res = append(res, ast)
+	}
+
+	return res
+}
+
+func normalizeArch(arch string) string {
+	return strings.ReplaceAll(strings.ToLower(arch), "-", "_")
+}
@@ -0,0 +1,104 @@
+package update
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestReleaseManifestCache(t *testing.T) {
+	rel := SourceRelease{
+		Tag:    "1.0.0",
+		Assets: []SourceAsset{{Name: manifestAssetName, Ref: "1.0.0/manifest.json"}},
+	}
+
+	src := &memSource{contents: map[string]string{"1.0.0/manifest.json": `{"notes": "first"}`}}
+
+	svc, err := New(src, nil, Config{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if m := svc.releaseManifest(context.Background(), src, rel); m == nil || m.Notes != "first" {
+			t.Fatalf("unexpected manifest: %+v", m)
+		}
+	}
+
+	if n := src.fetches("1.0.0/manifest.json"); n != 1 {
+		t.Errorf("got %d fetches, want 1", n)
+	}
+
+	// A manifest replaced at the same ref is read again once the cached one expires
+	src.contents["1.0.0/manifest.json"] = `{"notes": "second"}`
+
+	svc.manifests.mu.Lock()
+	item := svc.manifests.items["1.0.0/manifest.json"]
+	item.expires = time.Now().Add(-time.Second)
+	svc.manifests.items["1.0.0/manifest.json"] = item
+	svc.manifests.mu.Unlock()
+
+	if m := svc.releaseManifest(context.Background(), src, rel); m == nil || m.Notes != "second" {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+}
+
+func TestManifestAssetsHardware(t *testing.T) {
+	rel := SourceRelease{
+		Tag: "1.0.0",
+		Assets: []SourceAsset{
+			{Name: "cronus-esp32.bin"},
+			{Name: "cronus-esp32-4mb.bin"},
+			{Name: "cronus-esp32-s3.bin"},
+		},
+	}
+
+	m := &Manifest{Assets: []ManifestAsset{
+		{Name: "cronus-esp32.bin", Hardware: []string{"ESP32"}},
+		{Name: "cronus-esp32-4mb.bin", Hardware: []string{"esp32-4mb"}},
+		{Name: "cronus-esp32-s3.bin", Hardware: []string{"esp32-s3"}},
+	}}
+
+	tests := []struct {
+		name     string
+		hardware string
+		policy   AppPolicy
+		want     []string
+	}{
+		{
+			name:     "hardware id",
+			hardware: "esp32",
+			want:     []string{"cronus-esp32.bin"},
+		},
+		{
+			name:     "mapped hardware id",
+			hardware: "esp32",
+			policy:   AppPolicy{Hardware: map[string][]string{"esp32": {"esp32_4mb"}}},
+			want:     []string{"cronus-esp32.bin", "cronus-esp32-4mb.bin"},
+		},
+		{
+			name:     "mapped asset identifier",
+			hardware: "esp32s3",
+			policy:   AppPolicy{Hardware: map[string][]string{"esp32s3": {"esp32_s3"}}},
+			want:     []string{"cronus-esp32-s3.bin"},
+		},
+	}
+
+	svc := &Service{l: zerolog.Nop()}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, ast := range svc.manifestAssets(m, rel, tt.hardware, tt.policy.assetIDs(tt.hardware)) {
+				got = append(got, ast.Name)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package update
+
+import (
+	"strings"
+)
+
+var criticalMarkers = []string{"[critical]", "[security]"}
+
+// criticalRelease reports whether the release title or notes are marked with `[critical]` or `[security]`.
+func criticalRelease(rel SourceRelease) bool {
+	s := strings.ToLower(rel.Name + "\n" + rel.Notes)
+
+	for _, m := range criticalMarkers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NotesFor returns the release notes in the first of the languages they are localized to, or the default notes.
+//
+// Languages are tags in the order of preference, e.g. `pt-BR`; a tag also matches notes localized to its primary
+// language, e.g. `pt`.
+func (r Release) NotesFor(langs []string) string {
+	for _, lang := range langs {
+		for tag, notes := range r.LocalizedNotes {
+			if strings.EqualFold(tag, lang) {
+				return notes
+			}
+		}
+
+		primary, _, _ := strings.Cut(lang, "-")
+
+		for tag, notes := range r.LocalizedNotes {
+			if strings.EqualFold(tag, primary) {
+				return notes
+			}
+		}
+	}
+
+	return r.Notes
+}
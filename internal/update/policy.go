@@ -0,0 +1,243 @@
+package update
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const policyCheckInterval = 10 * time.Second
+
+// Policy contains update policies of apps keyed by `{owner}/{name}`.
+// Only the apps the policy lists are served, unless the service allows all apps.
+type Policy struct {
+	Apps map[string]AppPolicy `yaml:"apps"`
+
+	aliases       map[string]string
+	allowUnlisted bool // whether apps which are not listed are served too
+}
+
+type AppPolicy struct {
+	// Aliases are short names devices may refer to the app by, e.g. `cronus` for `ashep/cronus`.
+	Aliases []string `yaml:"aliases"`
+
+	// Arches limits the arches the app is served for; any arch is allowed if empty.
+	Arches []string `yaml:"arches"`
+
+	// Hardware maps hardware IDs to identifiers of compatible assets, e.g. `esp32: [esp32, esp32-4mb]`.
+	// Unmapped hardware is compatible with assets identified by the hardware ID.
+	Hardware map[string][]string `yaml:"hardware"`
+
+	// Rollouts limits the audience of releases, keyed by version.
+	// Releases without a rollout are offered to all devices.
+	Rollouts map[string]Rollout `yaml:"rollouts"`
+
+	// ExcludeFrom contains versions which cannot be upgraded from.
+	ExcludeFrom exclusionList `yaml:"exclude_from"`
+
+	// ExcludeTo contains versions which cannot be upgraded to.
+	ExcludeTo exclusionList `yaml:"exclude_to"`
+
+	// Waypoints contains versions which every device must be upgraded to before getting later releases.
+	Waypoints []string `yaml:"waypoints"`
+
+	// Channels configures update channels.
+	Channels map[Channel]ChannelPolicy `yaml:"channels"`
+
+	// Revoked contains pulled releases, keyed by version.
+	Revoked map[string]Revocation `yaml:"revoked"`
+
+	// UpdateWindow limits the time of day releases are offered at; releases are offered any time if omitted.
+	UpdateWindow *UpdateWindow `yaml:"update_window"`
+
+	// DeviceChannels assigns devices to channels, keyed by device ID.
+	// An assignment takes precedence over the channel requested by a device.
+	DeviceChannels map[string]Channel `yaml:"device_channels"`
+}
+
+// LoadPolicy reads and validates a YAML or JSON policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	p := &Policy{}
+	if err := yaml.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	if err := p.normalize(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// DefaultPolicy returns the policy used if no policy file is set. It excludes broken early alphas of ashep/cronus.
+func DefaultPolicy() *Policy {
+	p := &Policy{
+		Apps: map[string]AppPolicy{
+			"ashep/cronus": {
+				ExcludeFrom: exclusionList{
+					{
+						Versions: "0.0.1-alpha1 || 0.0.1-alpha2 || 0.0.1-alpha3 || 0.0.1-alpha4 || 0.0.1-alpha5",
+						Reason:   "early alphas cannot be upgraded over the air",
+					},
+				},
+				ExcludeTo: exclusionList{
+					{
+						Versions: "0.0.1-alpha2 || 0.0.1-alpha3 || 0.0.1-alpha4 || 0.0.1-alpha5",
+						Reason:   "broken alpha releases",
+					},
+				},
+			},
+		},
+	}
+
+	if err := p.normalize(); err != nil {
+		panic(fmt.Errorf("default policy: %w", err))
+	}
+
+	return p
+}
+
+// App returns the policy of the app.
+func (p *Policy) App(owner, name string) AppPolicy {
+	if p == nil {
+		return AppPolicy{}
+	}
+
+	return p.Apps[owner+"/"+name]
+}
+
+// normalize validates the policy, brings version keys to the canonical form and compiles version constraints.
+func (p *Policy) normalize() error {
+	if err := p.compileAliases(); err != nil {
+		return err
+	}
+
+	for app, appPol := range p.Apps {
+		rollouts := make(map[string]Rollout, len(appPol.Rollouts))
+
+		for verStr, ro := range appPol.Rollouts {
+			ver, err := semver.NewVersion(verStr)
+			if err != nil {
+				return fmt.Errorf("%s: rollout %s: invalid version: %w", app, verStr, err)
+			}
+
+			if err := ro.validate(); err != nil {
+				return fmt.Errorf("%s: rollout %s: %w", app, verStr, err)
+			}
+
+			rollouts[ver.String()] = ro
+		}
+
+		appPol.Rollouts = rollouts
+
+		hardware := make(map[string][]string, len(appPol.Hardware))
+
+		for hw, ids := range appPol.Hardware {
+			if len(ids) == 0 {
+				return fmt.Errorf("%s: hardware %s: no asset identifiers", app, hw)
+			}
+
+			for i, id := range ids {
+				ids[i] = normalizeArch(id)
+			}
+
+			hardware[normalizeArch(hw)] = ids
+		}
+
+		appPol.Hardware = hardware
+
+		if err := appPol.ExcludeFrom.compile(); err != nil {
+			return fmt.Errorf("%s: exclude_from: %w", app, err)
+		}
+
+		if err := appPol.ExcludeTo.compile(); err != nil {
+			return fmt.Errorf("%s: exclude_to: %w", app, err)
+		}
+
+		for i, verStr := range appPol.Waypoints {
+			ver, err := semver.NewVersion(verStr)
+			if err != nil {
+				return fmt.Errorf("%s: waypoint %s: invalid version: %w", app, verStr, err)
+			}
+
+			if _, ok := appPol.ExcludeTo.Match(ver); ok {
+				return fmt.Errorf("%s: waypoint %s: excluded by exclude_to", app, verStr)
+			}
+
+			appPol.Waypoints[i] = ver.String()
+		}
+
+		revoked := make(map[string]Revocation, len(appPol.Revoked))
+
+		for verStr, rev := range appPol.Revoked {
+			ver, err := semver.NewVersion(verStr)
+			if err != nil {
+				return fmt.Errorf("%s: revoked %s: invalid version: %w", app, verStr, err)
+			}
+
+			if err := rev.compile(ver); err != nil {
+				return fmt.Errorf("%s: revoked %s: %w", app, verStr, err)
+			}
+
+			revoked[ver.String()] = rev
+		}
+
+		appPol.Revoked = revoked
+
+		for ch := range appPol.Channels {
+			if _, err := ParseChannel(string(ch)); err != nil {
+				return fmt.Errorf("%s: channels: %w", app, err)
+			}
+		}
+
+		for devID, ch := range appPol.DeviceChannels {
+			if _, err := ParseChannel(string(ch)); err != nil {
+				return fmt.Errorf("%s: device_channels: %s: %w", app, devID, err)
+			}
+		}
+
+		if appPol.UpdateWindow != nil {
+			if err := appPol.UpdateWindow.compile(); err != nil {
+				return fmt.Errorf("%s: update_window: %w", app, err)
+			}
+		}
+
+		p.Apps[app] = appPol
+	}
+
+	return nil
+}
+
+// reloadPolicy loads the policy file if it has been modified since the previous call.
+// The current policy is kept if the file is invalid.
+func (s *Service) reloadPolicy() (bool, error) {
+	fi, err := os.Stat(s.policyFile)
+	if err != nil {
+		return false, fmt.Errorf("stat file: %w", err)
+	}
+
+	if fi.ModTime().Equal(s.policyModTime) {
+		return false, nil
+	}
+
+	// Remember the time even if loading fails, so an invalid file is reported once
+	s.policyModTime = fi.ModTime()
+
+	p, err := LoadPolicy(s.policyFile)
+	if err != nil {
+		return false, err
+	}
+
+	p.allowUnlisted = s.allowAllApps
+	s.policy.Store(p)
+
+	return true, nil
+}
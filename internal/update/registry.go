@@ -0,0 +1,86 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrArchNotSupported = errors.New("arch not supported")
+
+// known reports whether the app is served: listed in the policy, or any app if the service allows all apps.
+func (p *Policy) known(owner, name string) bool {
+	if p == nil {
+		return false
+	}
+
+	_, ok := p.Apps[owner+"/"+name]
+
+	return ok || p.allowUnlisted
+}
+
+// resolve returns the `{owner}/{name}` of the app referenced by the alias or by the full name.
+func (p *Policy) resolve(ref string) (string, string, bool) {
+	if p != nil {
+		if app, ok := p.aliases[ref]; ok {
+			ref = app
+		}
+	}
+
+	owner, name, ok := strings.Cut(ref, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+
+	return owner, name, p.known(owner, name)
+}
+
+// compileAliases validates aliases and arches of apps and indexes the aliases.
+func (p *Policy) compileAliases() error {
+	p.aliases = make(map[string]string)
+
+	for app, appPol := range p.Apps {
+		if owner, name, ok := strings.Cut(app, "/"); !ok || owner == "" || name == "" {
+			return fmt.Errorf("%s: app must be named as owner/name", app)
+		}
+
+		for _, alias := range appPol.Aliases {
+			if alias == "" || strings.ContainsAny(alias, "/:") {
+				return fmt.Errorf("%s: invalid alias: %q", app, alias)
+			}
+
+			if other, ok := p.aliases[alias]; ok {
+				return fmt.Errorf("%s: alias %s is already used by %s", app, alias, other)
+			}
+
+			p.aliases[alias] = app
+		}
+
+		for i, arch := range appPol.Arches {
+			appPol.Arches[i] = normalizeArch(arch)
+		}
+	}
+
+	return nil
+}
+
+// ResolveApp returns the owner and the name of the app referenced by an alias or by `{owner}/{name}`.
+//
+// ErrAppNotFound is returned if the app is not served, ErrArchNotSupported if the app is not built for the arch.
+// The arch is not checked if empty. Apps are checked without requesting their sources.
+func (s *Service) ResolveApp(ref, arch string) (string, string, error) {
+	p := s.policy.Load()
+
+	owner, name, ok := p.resolve(ref)
+	if !ok {
+		return "", "", ErrAppNotFound
+	}
+
+	arches := p.App(owner, name).Arches
+	if arch != "" && len(arches) != 0 && !slices.Contains(arches, normalizeArch(arch)) {
+		return "", "", ErrArchNotSupported
+	}
+
+	return owner, name, nil
+}
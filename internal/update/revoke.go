@@ -0,0 +1,66 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Revocation pulls a release: it is not offered anymore, and devices running it are downgraded.
+type Revocation struct {
+	RollbackTo string `yaml:"rollback_to"` // known-good version devices running the release are downgraded to
+	Reason     string `yaml:"reason"`
+
+	rollbackTo *semver.Version
+}
+
+func (r *Revocation) compile(ver *semver.Version) error {
+	if r.Reason == "" {
+		return errors.New("empty reason")
+	}
+
+	if r.RollbackTo == "" {
+		return nil
+	}
+
+	rbVer, err := semver.NewVersion(r.RollbackTo)
+	if err != nil {
+		return fmt.Errorf("invalid rollback version: %w", err)
+	}
+
+	if !rbVer.LessThan(ver) {
+		return errors.New("rollback version must be less than the revoked one")
+	}
+
+	r.rollbackTo = rbVer
+
+	return nil
+}
+
+// Revoked returns the revocation of the version, if any.
+func (r ReleaseSet) Revoked(v *semver.Version) (Revocation, bool) {
+	rev, ok := r.policy.Revoked[v.String()]
+	return rev, ok
+}
+
+// Rollback returns the release devices running the current version must be downgraded to.
+// Nothing is returned if the version is not revoked, or if the rollback release is not in the set.
+func (r ReleaseSet) Rollback(current *semver.Version) (*Release, Revocation) {
+	if current == nil {
+		return nil, Revocation{}
+	}
+
+	rev, ok := r.Revoked(current)
+	if !ok || rev.rollbackTo == nil {
+		return nil, rev
+	}
+
+	for i, rel := range r.List {
+		if rel.Version.Equal(rev.rollbackTo) {
+			return &r.List[i], rev
+		}
+	}
+
+	return nil, rev
+}
@@ -0,0 +1,90 @@
+package update
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/clientinfo"
+)
+
+func TestReleaseSetRevoked(t *testing.T) {
+	rlsSet := newTestReleaseSet(t, "    revoked: {1.1.0: {reason: bricks devices}}\n", Config{}, ChannelStable)
+
+	if got := nextVersion(rlsSet, "1.0.0", clientinfo.Info{}); got != "1.2.0" {
+		t.Errorf("next: got %q, want %q", got, "1.2.0")
+	}
+
+	want := []string{"1.2.0", "2.0.0"}
+	if got := pathVersions(rlsSet, "1.0.0", clientinfo.Info{}); !slices.Equal(got, want) {
+		t.Errorf("path: got %v, want %v", got, want)
+	}
+}
+
+func TestReleaseSetRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		current string
+		want    string
+	}{
+		{
+			name:    "rollback",
+			policy:  "    revoked: {2.0.0: {reason: bricks devices, rollback_to: 1.2.0}}\n",
+			current: "2.0.0",
+			want:    "1.2.0",
+		},
+		{
+			name:    "not revoked",
+			policy:  "    revoked: {2.0.0: {reason: bricks devices, rollback_to: 1.2.0}}\n",
+			current: "1.2.0",
+		},
+		{
+			name:    "no rollback version",
+			policy:  "    revoked: {2.0.0: {reason: bricks devices}}\n",
+			current: "2.0.0",
+		},
+		{
+			name:    "unknown rollback version",
+			policy:  "    revoked: {2.0.0: {reason: bricks devices, rollback_to: 0.9.0}}\n",
+			current: "2.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rlsSet := newTestReleaseSet(t, tt.policy, Config{}, ChannelStable)
+
+			got := ""
+			if rel, _ := rlsSet.Rollback(semver.MustParse(tt.current)); rel != nil {
+				got = rel.Version.String()
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevocationCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		rev     Revocation
+		wantErr bool
+	}{
+		{name: "valid", rev: Revocation{Reason: "broken", RollbackTo: "1.0.0"}},
+		{name: "no rollback", rev: Revocation{Reason: "broken"}},
+		{name: "empty reason", rev: Revocation{RollbackTo: "1.0.0"}, wantErr: true},
+		{name: "invalid rollback", rev: Revocation{Reason: "broken", RollbackTo: "x"}, wantErr: true},
+		{name: "rollback to newer", rev: Revocation{Reason: "broken", RollbackTo: "1.2.0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rev.compile(semver.MustParse("1.1.0")); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
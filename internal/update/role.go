@@ -0,0 +1,65 @@
+package update
+
+import (
+	"strings"
+)
+
+// AssetRole is the purpose of a release asset.
+type AssetRole string
+
+const (
+	AssetRoleApp            AssetRole = "app"
+	AssetRoleBootloader     AssetRole = "bootloader"
+	AssetRolePartitionTable AssetRole = "partition_table"
+	AssetRoleFilesystem     AssetRole = "filesystem"
+)
+
+// assetRole derives the role of an asset from its name.
+//
+// The name without the app name prefix is split into `-`, `_` and `.` separated tokens, which are looked up for
+// well-known words, e.g. `cronus-esp32-bootloader.bin` is a bootloader and `cronus-esp32-littlefs.bin` is
+// a filesystem image. Assets without such words are app images.
+func assetRole(appName, assetName string) AssetRole {
+	s := strings.TrimPrefix(strings.ToLower(assetName), strings.ToLower(appName))
+
+	toks := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+
+	for _, tok := range toks {
+		if role, ok := tokenRole(tok); ok {
+			return role
+		}
+	}
+
+	return AssetRoleApp
+}
+
+// tokenRole returns the role a well-known word of asset names stands for.
+func tokenRole(tok string) (AssetRole, bool) {
+	switch tok {
+	case "bootloader", "boot":
+		return AssetRoleBootloader, true
+	case "partition", "partitions", "ptable":
+		return AssetRolePartitionTable, true
+	case "fs", "filesystem", "spiffs", "littlefs", "fatfs":
+		return AssetRoleFilesystem, true
+	}
+
+	return "", false
+}
+
+// AppAsset returns the app image of the release, or the first asset if there is no app image.
+func (r Release) AppAsset() *Asset {
+	for i, ast := range r.Assets {
+		if ast.Role == AssetRoleApp {
+			return &r.Assets[i]
+		}
+	}
+
+	if len(r.Assets) != 0 {
+		return &r.Assets[0]
+	}
+
+	return nil
+}
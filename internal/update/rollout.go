@@ -0,0 +1,69 @@
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/ashep/d5y/internal/clientinfo"
+)
+
+// Rollout limits the audience of a release.
+//
+// A device is offered the release if its ID is allowlisted, or if it matches all the non-empty filters and falls
+// into the percentage. Devices are assigned to percentage buckets by their IDs deterministically, so raising the
+// percentage over time only adds devices to the audience.
+type Rollout struct {
+	Percent   *float64 `yaml:"percent"`   // share of devices, 0-100; 100 if omitted
+	Countries []string `yaml:"countries"` // ISO 3166-1 alpha-2 country codes
+	Hardware  []string `yaml:"hardware"`  // hardware revisions
+	Devices   []string `yaml:"devices"`   // device IDs which always get the release
+}
+
+func (r Rollout) validate() error {
+	if r.Percent != nil && (*r.Percent < 0 || *r.Percent > 100) {
+		return errors.New("percent is out of range")
+	}
+
+	return nil
+}
+
+// Allows reports whether the release of the app is offered to the device.
+func (r Rollout) Allows(app, version string, dev clientinfo.Info) bool {
+	if dev.ID != "" && slices.Contains(r.Devices, dev.ID) {
+		return true
+	}
+
+	if len(r.Countries) != 0 && !slices.ContainsFunc(r.Countries, func(c string) bool {
+		return strings.EqualFold(c, dev.CountryCode)
+	}) {
+		return false
+	}
+
+	if len(r.Hardware) != 0 && !slices.ContainsFunc(r.Hardware, func(h string) bool {
+		return strings.EqualFold(h, dev.Hardware)
+	}) {
+		return false
+	}
+
+	if r.Percent == nil || *r.Percent >= 100 {
+		return true
+	}
+
+	// Devices without an ID cannot be bucketed
+	if dev.ID == "" {
+		return false
+	}
+
+	return rolloutBucket(app, version, dev.ID) < *r.Percent
+}
+
+// rolloutBucket maps the device to a number in the [0, 100) range, stable for the release.
+//
+// The release is a part of the hash, so the same devices are not always the first to get updates.
+func rolloutBucket(app, version, devID string) float64 {
+	h := sha256.Sum256([]byte(app + "/" + version + "/" + devID))
+	return float64(binary.BigEndian.Uint64(h[:8])%10000) / 100
+}
@@ -0,0 +1,104 @@
+package update
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ashep/d5y/internal/clientinfo"
+)
+
+func TestReleaseSetNextRollouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		device clientinfo.Info
+		want   string
+	}{
+		{
+			name:   "excluded device",
+			policy: "    rollouts: {1.1.0: {percent: 0}}\n",
+			device: clientinfo.Info{ID: "dev1"},
+			want:   "1.2.0",
+		},
+		{
+			name:   "full rollout",
+			policy: "    rollouts: {1.1.0: {percent: 100}}\n",
+			device: clientinfo.Info{ID: "dev1"},
+			want:   "1.1.0",
+		},
+		{
+			name:   "allowlisted device",
+			policy: "    rollouts: {1.1.0: {percent: 0, devices: [dev1]}}\n",
+			device: clientinfo.Info{ID: "dev1"},
+			want:   "1.1.0",
+		},
+		{
+			name:   "matching country",
+			policy: "    rollouts: {1.1.0: {countries: [ua]}}\n",
+			device: clientinfo.Info{ID: "dev1", CountryCode: "UA"},
+			want:   "1.1.0",
+		},
+		{
+			name:   "other country",
+			policy: "    rollouts: {1.1.0: {countries: [ua]}}\n",
+			device: clientinfo.Info{ID: "dev1", CountryCode: "PL"},
+			want:   "1.2.0",
+		},
+		{
+			name:   "matching hardware",
+			policy: "    rollouts: {1.1.0: {hardware: [rev2]}}\n",
+			device: clientinfo.Info{ID: "dev1", Hardware: "REV2"},
+			want:   "1.1.0",
+		},
+		{
+			name:   "other hardware",
+			policy: "    rollouts: {1.1.0: {hardware: [rev2]}}\n",
+			device: clientinfo.Info{ID: "dev1", Hardware: "rev1"},
+			want:   "1.2.0",
+		},
+		{
+			name:   "device without id",
+			policy: "    rollouts: {1.1.0: {percent: 50}}\n",
+			want:   "1.2.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rlsSet := newTestReleaseSet(t, tt.policy, Config{}, ChannelStable)
+
+			if got := nextVersion(rlsSet, "1.0.0", tt.device); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRolloutPercent(t *testing.T) {
+	percents := []float64{0, 10, 50, 90, 100}
+	allowed := make(map[string]bool)
+
+	for _, percent := range percents {
+		ro := Rollout{Percent: &percent}
+		n := 0
+
+		for i := range 1000 {
+			devID := fmt.Sprintf("dev%d", i)
+
+			ok := ro.Allows("ashep/cronus", "1.1.0", clientinfo.Info{ID: devID})
+			if allowed[devID] && !ok {
+				t.Fatalf("%s is not allowed at %v%% anymore", devID, percent)
+			}
+
+			allowed[devID] = ok
+			if ok {
+				n++
+			}
+		}
+
+		// Buckets are uniform enough to keep the share within a few percent
+		if share := float64(n) / 10; share < percent-5 || share > percent+5 {
+			t.Errorf("%v%%: got %v%% of devices", percent, share)
+		}
+	}
+}
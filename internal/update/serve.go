@@ -0,0 +1,76 @@
+package update
+
+import (
+	"context"
+	"io"
+	"slices"
+)
+
+// AssetMirror serves release assets to devices in place of their origins.
+type AssetMirror interface {
+	// Add registers the function opening the asset with the checksum and size, zero if unknown, and returns the URL
+	// the asset is served from, or an empty string if the asset is not mirrored. Requests of assets which turn out too
+	// large to mirror are redirected to the origin URL.
+	Add(sha256 string, size int64, origin string, fetch func(ctx context.Context) (io.ReadCloser, error)) string
+}
+
+// AssetLinker issues short-lived download URLs of assets which origins devices cannot access.
+type AssetLinker interface {
+	// Link returns a download URL of the asset of the app.
+	Link(owner, name, ref string) string
+}
+
+// servedAssets replaces origin URLs of the assets with URLs they are served to devices from.
+//
+// Assets with checksums are served by the mirror, if any, unless they are too large to mirror. Assets of private apps
+// are served by signed links. Other assets are served from their origins.
+func (s *Service) servedAssets(owner, name string, src ReleaseSource, rel SourceRelease, assets []Asset) []Asset {
+	private := s.linker != nil && slices.Contains(s.privateApps, owner+"/"+name)
+
+	if s.mirror == nil && !private {
+		return assets
+	}
+
+	for i, ast := range assets {
+		idx := slices.IndexFunc(rel.Assets, func(a SourceAsset) bool { return a.Name == ast.Name })
+		if idx < 0 {
+			continue
+		}
+
+		srcAst := rel.Assets[idx]
+
+		if private {
+			assets[i].URL = s.linker.Link(owner, name, srcAst.Ref)
+		}
+
+		if s.mirror != nil && ast.SHA256 != "" {
+			u := s.mirror.Add(ast.SHA256, int64(ast.Size), assets[i].URL, func(ctx context.Context) (io.ReadCloser, error) {
+				return src.Fetch(ctx, srcAst)
+			})
+			if u != "" {
+				assets[i].URL = u
+			}
+		}
+	}
+
+	return assets
+}
+
+// AuthRequired reports whether releases of the app are offered to authenticated devices only.
+func (s *Service) AuthRequired(owner, name string) bool {
+	return s.privateAuth && slices.Contains(s.privateApps, owner+"/"+name)
+}
+
+// OpenAsset returns a temporary direct URL of the app asset, or the asset content if the source cannot issue one.
+func (s *Service) OpenAsset(ctx context.Context, owner, name, ref string) (string, io.ReadCloser, error) {
+	src := s.source(owner, name)
+	ast := SourceAsset{Ref: ref}
+
+	if loc, ok := src.(AssetLocator); ok {
+		return loc.Locate(ctx, ast)
+	}
+
+	rc, err := src.Fetch(ctx, ast)
+
+	return "", rc, err
+}
@@ -0,0 +1,57 @@
+package update
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// assetFetchTimeout limits the time of downloading an asset from its origin.
+const assetFetchTimeout = 10 * time.Minute
+
+// assetClient downloads assets from their origins.
+var assetClient = &http.Client{Timeout: assetFetchTimeout}
+
+// SourceAsset is a release file as reported by a ReleaseSource.
+type SourceAsset struct {
+	Name string
+	Size int
+	URL  string // public download URL handed to devices
+	Ref  string // source specific reference used by ReleaseSource.Fetch
+
+	// SHA256 is the asset checksum if the source knows it;
+	// otherwise it is read from the `{name}.sha256` sidecar or the `SHA256SUMS` asset.
+	SHA256 string
+
+	// SHA512 is the optional asset checksum if the source knows it;
+	// otherwise it is read from the `{name}.sha512` sidecar or the `SHA512SUMS` asset.
+	SHA512 string
+}
+
+// SourceRelease is a release as reported by a ReleaseSource.
+type SourceRelease struct {
+	Tag         string
+	Name        string // release title
+	Notes       string // release description, usually Markdown
+	PublishedAt time.Time
+	Prerelease  bool
+	Draft       bool
+	Assets      []SourceAsset
+}
+
+// ReleaseSource provides releases of apps.
+type ReleaseSource interface {
+	// Releases returns all the releases of the app in no particular order.
+	// ErrAppNotFound is returned if the source does not know the app.
+	Releases(ctx context.Context, owner, name string) ([]SourceRelease, error)
+
+	// Fetch opens the asset for reading.
+	Fetch(ctx context.Context, ast SourceAsset) (io.ReadCloser, error)
+}
+
+// AssetLocator is implemented by sources which can issue temporary direct URLs of assets, e.g. of private ones.
+type AssetLocator interface {
+	// Locate returns the temporary URL of the asset, or the asset content if the source has no such URL for it.
+	Locate(ctx context.Context, ast SourceAsset) (string, io.ReadCloser, error)
+}
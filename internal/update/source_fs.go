@@ -0,0 +1,95 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// FSSource provides releases stored in a local directory tree.
+//
+// The tree is laid out as `{dir}/{owner}/{name}/{version}/{asset}`, checksums are stored in `{asset}.sha256` files
+// next to assets. Assets are downloaded by devices from `{baseURL}/{owner}/{name}/{version}/{asset}`.
+type FSSource struct {
+	dir     string
+	baseURL string
+}
+
+func NewFSSource(dir, baseURL string) *FSSource {
+	return &FSSource{
+		dir:     dir,
+		baseURL: baseURL,
+	}
+}
+
+func (s *FSSource) Releases(_ context.Context, owner, name string) ([]SourceRelease, error) {
+	appDir := filepath.Join(s.dir, filepath.Clean("/"+owner), filepath.Clean("/"+name))
+
+	verDirs, err := os.ReadDir(appDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrAppNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("read app dir: %w", err)
+	}
+
+	res := make([]SourceRelease, 0, len(verDirs))
+
+	for _, verDir := range verDirs {
+		if !verDir.IsDir() {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(appDir, verDir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read release dir: %w", err)
+		}
+
+		rel := SourceRelease{
+			Tag:    verDir.Name(),
+			Assets: make([]SourceAsset, 0, len(files)),
+		}
+
+		for _, f := range files {
+			if !f.Type().IsRegular() {
+				continue
+			}
+
+			fi, err := f.Info()
+			if err != nil {
+				return nil, fmt.Errorf("stat asset: %w", err)
+			}
+
+			var u string
+			if s.baseURL != "" {
+				if u, err = url.JoinPath(s.baseURL, owner, name, verDir.Name(), f.Name()); err != nil {
+					return nil, fmt.Errorf("build asset url: %w", err)
+				}
+			}
+
+			rel.Assets = append(rel.Assets, SourceAsset{
+				Name: f.Name(),
+				Size: int(fi.Size()),
+				URL:  u,
+				Ref:  filepath.Join(appDir, verDir.Name(), f.Name()),
+			})
+		}
+
+		res = append(res, rel)
+	}
+
+	return res, nil
+}
+
+func (s *FSSource) Fetch(_ context.Context, ast SourceAsset) (io.ReadCloser, error) {
+	f, err := os.Open(ast.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("open asset: %w", err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,320 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v63/github"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+const githubRateReserve = 0.1 // share of the API rate limit kept unused
+
+// GitHubSource provides releases published on GitHub.
+//
+// Release pages are requested conditionally using ETags of previous responses,
+// so unchanged pages do not count against the API rate limit.
+//
+// The source tracks the API rate limit and stops making requests when the remaining quota drops below the reserve,
+// until the limit resets.
+type GitHubSource struct {
+	gh           *github.Client
+	mu           sync.Mutex
+	pages        map[string]githubPage
+	rate         github.Rate
+	blockedUntil time.Time
+	l            zerolog.Logger
+}
+
+type githubPage struct {
+	etag string
+	rels []*github.RepositoryRelease
+}
+
+func NewGitHubSource(gh *github.Client, l zerolog.Logger) *GitHubSource {
+	s := &GitHubSource{
+		gh:    gh,
+		pages: make(map[string]githubPage),
+		l:     l,
+	}
+
+	if err := prometheus.Register(githubRateCollector{s: s}); err != nil {
+		l.Warn().Err(err).Msg("failed to register github rate limit metrics")
+	}
+
+	return s
+}
+
+func (s *GitHubSource) Releases(ctx context.Context, owner, name string) ([]SourceRelease, error) {
+	res := make([]SourceRelease, 0)
+
+	for page := 1; ; page++ {
+		rsp, err := s.listReleases(ctx, owner, name, page)
+
+		ghErr := &github.ErrorResponse{}
+		if errors.As(err, &ghErr) && ghErr.Response.StatusCode == http.StatusNotFound {
+			return nil, ErrAppNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf("gitbhub: list releases: %w", err)
+		}
+
+		if len(rsp) == 0 {
+			break
+		}
+
+		for _, ghRel := range rsp {
+			rel := SourceRelease{
+				Tag:         ghRel.GetTagName(),
+				Name:        ghRel.GetName(),
+				Notes:       ghRel.GetBody(),
+				PublishedAt: ghRel.GetPublishedAt().Time,
+				Prerelease:  ghRel.GetPrerelease(),
+				Draft:       ghRel.GetDraft(),
+				Assets:      make([]SourceAsset, 0, len(ghRel.Assets)),
+			}
+
+			for _, ast := range ghRel.Assets {
+				rel.Assets = append(rel.Assets, SourceAsset{
+					Name: ast.GetName(),
+					Size: ast.GetSize(),
+					URL:  ast.GetBrowserDownloadURL(),
+					Ref:  owner + "/" + name + "/" + strconv.FormatInt(ast.GetID(), 10),
+				})
+			}
+
+			res = append(res, rel)
+		}
+	}
+
+	return res, nil
+}
+
+// listReleases returns a page of releases, reusing the previous response if the page is not modified.
+func (s *GitHubSource) listReleases(
+	ctx context.Context,
+	owner string,
+	name string,
+	page int,
+) ([]*github.RepositoryRelease, error) {
+	key := owner + "/" + name + "/" + strconv.Itoa(page)
+
+	if err := s.checkRate(); err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("repos/%s/%s/releases?page=%d", url.PathEscape(owner), url.PathEscape(name), page)
+	req, err := s.gh.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	s.mu.Lock()
+	cached, ok := s.pages[key]
+	s.mu.Unlock()
+
+	if ok {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	var rels []*github.RepositoryRelease
+
+	rsp, err := s.gh.Do(ctx, req, &rels)
+	s.updateRate(rsp, err)
+
+	if ok && rsp != nil && rsp.StatusCode == http.StatusNotModified {
+		return cached.rels, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if etag := rsp.Header.Get("ETag"); etag != "" {
+		s.mu.Lock()
+		s.pages[key] = githubPage{etag: etag, rels: rels}
+		s.mu.Unlock()
+	}
+
+	return rels, nil
+}
+
+// checkRate returns an error if requests must be postponed to save the rate limit.
+func (s *GitHubSource) checkRate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Before(s.blockedUntil) {
+		return fmt.Errorf("%w: retry after %s", ErrRateLimited, s.blockedUntil.Format(time.RFC3339))
+	}
+
+	reset := s.rate.Reset.Time
+	if s.rate.Limit > 0 && float64(s.rate.Remaining) < float64(s.rate.Limit)*githubRateReserve && now.Before(reset) {
+		return fmt.Errorf("%w: %d of %d requests remaining until %s",
+			ErrRateLimited, s.rate.Remaining, s.rate.Limit, reset.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// updateRate remembers the rate limit reported by the response or the error; rsp may be nil.
+func (s *GitHubSource) updateRate(rsp *github.Response, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rlErr := &github.RateLimitError{}
+	abErr := &github.AbuseRateLimitError{}
+
+	switch {
+	case errors.As(err, &rlErr):
+		s.rate = rlErr.Rate
+		s.blockedUntil = rlErr.Rate.Reset.Time
+	case errors.As(err, &abErr):
+		retryAfter := time.Minute
+		if abErr.RetryAfter != nil {
+			retryAfter = *abErr.RetryAfter
+		}
+		s.blockedUntil = time.Now().Add(retryAfter)
+	case rsp != nil && rsp.Rate.Limit > 0:
+		s.rate = rsp.Rate
+	default:
+		return
+	}
+
+	s.l.Debug().
+		Int("limit", s.rate.Limit).
+		Int("remaining", s.rate.Remaining).
+		Time("reset", s.rate.Reset.Time).
+		Time("blocked_until", s.blockedUntil).
+		Msg("github rate limit")
+}
+
+// Fetch downloads the asset through the API, so assets of private repositories are available too.
+func (s *GitHubSource) Fetch(ctx context.Context, ast SourceAsset) (io.ReadCloser, error) {
+	owner, name, id, err := parseGitHubAssetRef(ast.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkRate(); err != nil {
+		return nil, err
+	}
+
+	// Responses are not returned by the client, so only rate limit errors are recorded
+	rc, _, err := s.gh.Repositories.DownloadReleaseAsset(ctx, owner, name, id, assetClient)
+	s.updateRate(nil, err)
+	if err != nil {
+		return nil, fmt.Errorf("github: download release asset: %w", err)
+	}
+
+	return rc, nil
+}
+
+// Locate returns the temporary URL GitHub redirects asset downloads to. The asset content is returned instead if there
+// is no redirect.
+func (s *GitHubSource) Locate(ctx context.Context, ast SourceAsset) (string, io.ReadCloser, error) {
+	owner, name, id, err := parseGitHubAssetRef(ast.Ref)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := s.checkRate(); err != nil {
+		return "", nil, err
+	}
+
+	rc, u, err := s.gh.Repositories.DownloadReleaseAsset(ctx, owner, name, id, nil)
+	s.updateRate(nil, err)
+	if err != nil {
+		return "", nil, fmt.Errorf("github: download release asset: %w", err)
+	}
+
+	return u, rc, nil
+}
+
+// parseGitHubAssetRef parses the `{owner}/{name}/{asset_id}` asset reference.
+func parseGitHubAssetRef(ref string) (string, string, int64, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 3 {
+		return "", "", 0, fmt.Errorf("invalid asset ref: %s", ref)
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid asset ref: %s", ref)
+	}
+
+	return parts[0], parts[1], id, nil
+}
+
+func httpFetch(ctx context.Context, cli *http.Client, u string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	res, err := cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("bad response: %s", res.Status)
+	}
+
+	return res.Body, nil
+}
+
+var (
+	githubRateLimitDesc = prometheus.NewDesc(
+		"d5y_cloud_github_rate_limit",
+		"GitHub API requests per hour the server is limited to",
+		nil,
+		nil,
+	)
+	githubRateRemainingDesc = prometheus.NewDesc(
+		"d5y_cloud_github_rate_remaining",
+		"GitHub API requests remaining until the rate limit resets",
+		nil,
+		nil,
+	)
+	githubRateResetDesc = prometheus.NewDesc(
+		"d5y_cloud_github_rate_reset_timestamp_seconds",
+		"Unix time the GitHub API rate limit resets at",
+		nil,
+		nil,
+	)
+)
+
+// githubRateCollector exposes the last known GitHub API rate limit.
+type githubRateCollector struct {
+	s *GitHubSource
+}
+
+func (c githubRateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- githubRateLimitDesc
+	ch <- githubRateRemainingDesc
+	ch <- githubRateResetDesc
+}
+
+func (c githubRateCollector) Collect(ch chan<- prometheus.Metric) {
+	c.s.mu.Lock()
+	rate := c.s.rate
+	c.s.mu.Unlock()
+
+	if rate.Limit == 0 {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(githubRateLimitDesc, prometheus.GaugeValue, float64(rate.Limit))
+	ch <- prometheus.MustNewConstMetric(githubRateRemainingDesc, prometheus.GaugeValue, float64(rate.Remaining))
+	ch <- prometheus.MustNewConstMetric(githubRateResetDesc, prometheus.GaugeValue, float64(rate.Reset.Unix()))
+}
@@ -0,0 +1,154 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HTTPSource provides releases described by JSON index files served over HTTP, e.g. from an S3-compatible bucket.
+//
+// The index of an app is fetched from `{baseURL}/{owner}/{name}/index.json`.
+// Relative asset URLs are resolved against the index URL.
+// Indexes are requested conditionally using ETags of previous responses.
+type HTTPSource struct {
+	baseURL string
+	cli     *http.Client
+	mu      sync.Mutex
+	indexes map[string]httpCachedIndex
+}
+
+type httpCachedIndex struct {
+	etag string
+	rels []SourceRelease
+}
+
+type httpIndex struct {
+	Releases []httpIndexRelease `json:"releases"`
+}
+
+type httpIndexRelease struct {
+	Version     string           `json:"version"`
+	Name        string           `json:"name"`
+	Notes       string           `json:"notes"`
+	PublishedAt time.Time        `json:"published_at"`
+	Prerelease  bool             `json:"prerelease"`
+	Draft       bool             `json:"draft"`
+	Assets      []httpIndexAsset `json:"assets"`
+}
+
+type httpIndexAsset struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	SHA512 string `json:"sha512"`
+}
+
+// httpIndexTimeout limits the time of fetching an index.
+const httpIndexTimeout = 30 * time.Second
+
+func NewHTTPSource(baseURL string) *HTTPSource {
+	return &HTTPSource{
+		baseURL: baseURL,
+		cli:     assetClient,
+		indexes: make(map[string]httpCachedIndex),
+	}
+}
+
+func (s *HTTPSource) Releases(ctx context.Context, owner, name string) ([]SourceRelease, error) {
+	idxURLStr, err := url.JoinPath(s.baseURL, owner, name, "index.json")
+	if err != nil {
+		return nil, fmt.Errorf("build index url: %w", err)
+	}
+
+	idxURL, err := url.Parse(idxURLStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse index url: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpIndexTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, idxURLStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	s.mu.Lock()
+	cached, ok := s.indexes[idxURLStr]
+	s.mu.Unlock()
+
+	if ok {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	rsp, err := s.cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch index: %w", err)
+	}
+
+	defer rsp.Body.Close() //nolint:errcheck // ok
+
+	if ok && rsp.StatusCode == http.StatusNotModified {
+		return cached.rels, nil
+	} else if rsp.StatusCode == http.StatusNotFound || rsp.StatusCode == http.StatusForbidden {
+		return nil, ErrAppNotFound
+	} else if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch index: bad response: %s", rsp.Status)
+	}
+
+	idx := httpIndex{}
+	if err := json.NewDecoder(rsp.Body).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("decode index: %w", err)
+	}
+
+	res := make([]SourceRelease, 0, len(idx.Releases))
+
+	for _, idxRel := range idx.Releases {
+		rel := SourceRelease{
+			Tag:         idxRel.Version,
+			Name:        idxRel.Name,
+			Notes:       idxRel.Notes,
+			PublishedAt: idxRel.PublishedAt,
+			Prerelease:  idxRel.Prerelease,
+			Draft:       idxRel.Draft,
+			Assets:      make([]SourceAsset, 0, len(idxRel.Assets)),
+		}
+
+		for _, idxAst := range idxRel.Assets {
+			astURL, err := idxURL.Parse(idxAst.URL)
+			if err != nil {
+				return nil, fmt.Errorf("parse asset url: %w", err)
+			}
+
+			rel.Assets = append(rel.Assets, SourceAsset{
+				Name:   idxAst.Name,
+				Size:   idxAst.Size,
+				URL:    astURL.String(),
+				Ref:    astURL.String(),
+				SHA256: idxAst.SHA256,
+				SHA512: idxAst.SHA512,
+			})
+		}
+
+		res = append(res, rel)
+	}
+
+	if etag := rsp.Header.Get("ETag"); etag != "" {
+		s.mu.Lock()
+		s.indexes[idxURLStr] = httpCachedIndex{etag: etag, rels: res}
+		s.mu.Unlock()
+	}
+
+	return res, nil
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context, ast SourceAsset) (io.ReadCloser, error) {
+	return httpFetch(ctx, s.cli, ast.Ref)
+}
@@ -2,179 +2,324 @@ package update
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
-	"github.com/google/go-github/v63/github"
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
 )
 
 type Asset struct {
-	Name   string `json:"name"`
-	Size   int    `json:"size"`
-	SHA256 string `json:"sha256"`
-	URL    string `json:"url"`
+	Name          string    `json:"name"`
+	Role          AssetRole `json:"role"`
+	Size          int       `json:"size"`
+	SHA256        string    `json:"sha256"`
+	SHA512        string    `json:"sha512,omitempty"`
+	URL           string    `json:"url"`
+	MinBootloader string    `json:"min_bootloader,omitempty"`
 }
 
 type Release struct {
-	Version *semver.Version `json:"version"`
-	Assets  []Asset         `json:"assets"`
+	Version        *semver.Version   `json:"version"`
+	Channel        Channel           `json:"channel"`
+	Assets         []Asset           `json:"assets"`
+	Deltas         []Delta           `json:"deltas,omitempty"`
+	Waypoint       bool              `json:"waypoint"` // the release cannot be skipped by updates
+	Title          string            `json:"title,omitempty"`
+	Notes          string            `json:"notes,omitempty"`
+	LocalizedNotes map[string]string `json:"localized_notes,omitempty"`
+	PublishedAt    time.Time         `json:"published_at,omitzero"`
+	Critical       bool              `json:"critical,omitempty"` // the release fixes a critical or security issue
 }
 
 type ReleaseSet struct {
-	Owner string
-	Name  string
-	List  []Release
+	Owner  string
+	Name   string
+	List   []Release
+	policy AppPolicy
+	halter Halter
 }
 
-// Next returns the release which version number is after v.
-func (r ReleaseSet) Next(current *semver.Version) *Release {
+// Halter reports whether rollout of a release is halted, e.g. because devices fail to install it.
+type Halter interface {
+	Halted(owner, name, version string) bool
+}
+
+// Next returns the release which version number is after v and which is offered to the device.
+//
+// Excluded and revoked releases and releases which are not offered to the device are skipped, unless they are
+// waypoints: nothing is returned until a waypoint becomes available.
+func (r ReleaseSet) Next(current *semver.Version, dev clientinfo.Info) *Release {
 	if current == nil {
 		return nil
 	}
 
-	if excVerSrc.Contains(r.Owner, r.Name, current) {
+	if _, ok := r.ExcludedFrom(current); ok {
 		return nil
 	}
 
 	for i, next := range r.List {
-		if excVerDst.Contains(r.Owner, r.Name, next.Version) {
+		if !next.Version.GreaterThan(current) {
 			continue
 		}
 
-		if next.Version.GreaterThan(current) {
-			return &r.List[i]
+		_, excluded := r.ExcludedTo(next.Version)
+		if excluded || !r.offered(next, dev) {
+			if next.Waypoint {
+				return nil
+			}
+			continue
 		}
+
+		return &r.List[i]
 	}
 
 	return nil
 }
 
+func (r ReleaseSet) offered(rel Release, dev clientinfo.Info) bool {
+	if _, ok := r.Revoked(rel.Version); ok {
+		return false
+	}
+
+	verStr := rel.Version.String()
+
+	if r.halter != nil && r.halter.Halted(r.Owner, r.Name, verStr) {
+		return false
+	}
+
+	if ro, ok := r.policy.Rollouts[verStr]; ok && !ro.Allows(r.Owner+"/"+r.Name, verStr, dev) {
+		return false
+	}
+
+	return true
+}
+
+// Path returns the releases the device goes through to get from the current version to the latest available one.
+func (r ReleaseSet) Path(current *semver.Version, dev clientinfo.Info) []Release {
+	res := make([]Release, 0)
+
+	for next := r.Next(current, dev); next != nil; next = r.Next(current, dev) {
+		res = append(res, *next)
+		current = next.Version
+	}
+
+	return res
+}
+
+// ExcludedFrom returns the exclusion which forbids updates from the version, if any.
+func (r ReleaseSet) ExcludedFrom(v *semver.Version) (Exclusion, bool) {
+	return r.policy.ExcludeFrom.Match(v)
+}
+
+// ExcludedTo returns the exclusion which forbids updates to the version, if any.
+func (r ReleaseSet) ExcludedTo(v *semver.Version) (Exclusion, bool) {
+	return r.policy.ExcludeTo.Match(v)
+}
+
+type Config struct {
+	RefreshInterval time.Duration // how often releases of known apps are reloaded in background
+	MaxAge          time.Duration // max age of releases served without reloading them synchronously
+	PolicyFile      string        // YAML or JSON file with app update policies, reloaded on change; DefaultPolicy if empty
+	Halter          Halter        // optional
+	Verifier        AssetVerifier // if set, only assets with valid detached signatures are offered
+	Mirror          AssetMirror   // if set, assets are served by the mirror instead of their origins
+	StrictChecksums bool          // if set, assets without valid SHA256 checksums are not offered
+	Linker          AssetLinker   // issues download URLs of assets of private apps
+	PrivateApps     []string      // apps which assets are served by the linker, as `{owner}/{name}`
+	PrivateAuth     bool          // if set, releases of private apps are offered to authenticated devices only
+	AllowAllApps    bool          // if set, apps which are not listed in the policy are served too
+}
+
 type Service struct {
-	gh            *github.Client
-	checkSumCache map[string]string
-	l             zerolog.Logger
+	src             ReleaseSource
+	appSrc          map[string]ReleaseSource
+	refreshInterval time.Duration
+	maxAge          time.Duration
+	policyFile      string
+	policy          atomic.Pointer[Policy]
+	policyModTime   time.Time
+	halter          Halter
+	verifier        AssetVerifier
+	mirror          AssetMirror
+	strictChecksums bool
+	linker          AssetLinker
+	privateApps     []string
+	privateAuth     bool
+	allowAllApps    bool
+	sigs            *refCache[string]
+	checksums       *refCache[string]
+	manifests       *refCache[*Manifest]
+	refreshSF       singleflight.Group
+	mu              sync.RWMutex
+	snapshots       map[string]*snapshot
+	l               zerolog.Logger
 }
 
-func New(gh *github.Client, l zerolog.Logger) *Service {
-	return &Service{
-		gh:            gh,
-		checkSumCache: make(map[string]string),
-		l:             l,
+// New creates a new update service.
+//
+// Releases are taken from src unless appSrc contains a source for the app, keyed by `{owner}/{name}`.
+func New(src ReleaseSource, appSrc map[string]ReleaseSource, cfg Config, l zerolog.Logger) (*Service, error) {
+	if appSrc == nil {
+		appSrc = make(map[string]ReleaseSource)
+	}
+
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultRefreshInterval
+	}
+
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultMaxAge
 	}
+
+	s := &Service{
+		src:             src,
+		appSrc:          appSrc,
+		refreshInterval: cfg.RefreshInterval,
+		maxAge:          cfg.MaxAge,
+		policyFile:      cfg.PolicyFile,
+		halter:          cfg.Halter,
+		verifier:        cfg.Verifier,
+		mirror:          cfg.Mirror,
+		strictChecksums: cfg.StrictChecksums,
+		linker:          cfg.Linker,
+		privateApps:     cfg.PrivateApps,
+		privateAuth:     cfg.PrivateAuth,
+		allowAllApps:    cfg.AllowAllApps,
+		sigs:            newRefCache[string](sigCacheSize, sigCacheTTL, sigCacheErrorTTL),
+		checksums:       newRefCache[string](checksumCacheSize, checksumCacheTTL, checksumCacheErrorTTL),
+		manifests:       newRefCache[*Manifest](manifestCacheSize, manifestCacheTTL, manifestCacheErrorTTL),
+		snapshots:       make(map[string]*snapshot),
+		l:               l,
+	}
+
+	if s.policyFile != "" {
+		if _, err := s.reloadPolicy(); err != nil {
+			return nil, fmt.Errorf("load policy: %w", err)
+		}
+	} else {
+		p := DefaultPolicy()
+		p.allowUnlisted = s.allowAllApps
+		s.policy.Store(p)
+	}
+
+	if err := prometheus.Register(snapshotAgeCollector{s: s}); err != nil {
+		l.Warn().Err(err).Msg("failed to register snapshot age metric")
+	}
+
+	return s, nil
 }
 
 // List returns all available assets for all releases sorted by version in ascending order.
 //
-// Only assets named `{name}-{arch}*` are returned.
+// Only assets compatible with the arch, i.e. the hardware ID, are returned: ones declared by the release manifest, or
+// ones which names carry one of the identifiers mapped to the hardware by the app policy.
 //
-// `incAlpha` arg controls whether assets named `*-alpha*` are returned.
+// Only releases available on the `ch` update channel are returned.
 func (s *Service) List(
 	ctx context.Context,
 	repoOwner string,
 	repoName string,
 	arch string,
-	incAlpha bool,
+	ch Channel,
 ) (*ReleaseSet, error) {
+	if !s.policy.Load().known(repoOwner, repoName) {
+		return nil, ErrAppNotFound
+	}
+
 	res := &ReleaseSet{
-		Owner: repoOwner,
-		Name:  repoName,
-		List:  make([]Release, 0),
+		Owner:  repoOwner,
+		Name:   repoName,
+		List:   make([]Release, 0),
+		policy: s.policy.Load().App(repoOwner, repoName),
+		halter: s.halter,
 	}
 
-	arch = strings.ReplaceAll(strings.ToLower(arch), "-", "_")
+	arch = normalizeArch(arch)
+	astIDs := res.policy.assetIDs(arch)
+	repoFullName := repoOwner + "/" + repoName
 
-	for page := 1; ; page++ {
-		rsp, _, err := s.gh.Repositories.ListReleases(ctx, repoOwner, repoName, &github.ListOptions{Page: page})
+	src := s.source(repoOwner, repoName)
 
-		ghErr := &github.ErrorResponse{}
-		if errors.As(err, &ghErr) && ghErr.Response.StatusCode == http.StatusNotFound {
-			return nil, ErrAppNotFound
-		} else if err != nil {
-			return nil, fmt.Errorf("gitbhub: list releases: %w", err)
-		}
+	srcRels, err := s.releases(ctx, repoOwner, repoName)
+	if err != nil {
+		return nil, err
+	}
 
-		if len(rsp) == 0 {
-			break
-		}
+	for _, srcRel := range srcRels {
+		tagName := srcRel.Tag
 
-		for _, ghRel := range rsp {
-			repoFullName := repoOwner + "/" + repoName
-			tagName := ghRel.GetTagName()
+		s.l.Debug().
+			Str("repo", repoFullName).
+			Str("tag_name", tagName).
+			Msg("found release tag")
 
+		ver, err := semver.NewVersion(tagName)
+		if err != nil {
+			s.l.Error().
+				Str("repo", repoFullName).
+				Str("tag_name", tagName).
+				Err(err).
+				Msg("failed to parse a version from release tag name")
+			continue
+		}
+
+		relCh := releaseChannel(ver, srcRel.SourceRelease)
+		if !res.policy.channelAllows(ch, relCh) {
 			s.l.Debug().
 				Str("repo", repoFullName).
 				Str("tag_name", tagName).
-				Msg("found release tag")
-
-			if strings.Contains(tagName, "-alpha") && !incAlpha {
-				s.l.Debug().
-					Str("repo", repoFullName).
-					Str("tag_name", tagName).
-					Msg("skip release: alpha releases is not allowed")
-				continue
-			}
+				Str("channel", string(ch)).
+				Str("release_channel", string(relCh)).
+				Msg("skip release: channel is not allowed")
+			continue
+		}
 
-			ver, err := semver.NewVersion(tagName)
-			if err != nil {
-				s.l.Error().
-					Str("repo", repoFullName).
-					Str("tag_name", tagName).
-					Err(err).
-					Msg("failed to parse a version from GitHub tag name")
-				continue
-			}
+		rel := Release{
+			Version:     ver,
+			Channel:     relCh,
+			Assets:      make([]Asset, 0),
+			Waypoint:    slices.Contains(res.policy.Waypoints, ver.String()),
+			Title:       srcRel.Name,
+			Notes:       srcRel.Notes,
+			PublishedAt: srcRel.PublishedAt,
+			Critical:    criticalRelease(srcRel.SourceRelease),
+		}
 
-			rel := Release{
-				Version: ver,
-				Assets:  make([]Asset, 0),
-			}
+		if srcRel.manifest != nil {
+			rel.Assets = s.manifestAssets(srcRel.manifest, srcRel.SourceRelease, arch, astIDs)
+			rel.LocalizedNotes = srcRel.manifest.LocalizedNotes
+			rel.Critical = rel.Critical || srcRel.manifest.Critical
 
-			for _, ast := range ghRel.Assets {
-				if strings.HasSuffix(ast.GetName(), ".sha256") {
-					continue
-				}
-
-				if !strings.HasPrefix(ast.GetName(), repoName) {
-					s.l.Debug().
-						Str("repo", repoFullName).
-						Str("tag_name", tagName).
-						Str("asset_name", ast.GetName()).
-						Msg("skip asset: name does not match app")
-					continue
-				}
-
-				if !strings.Contains(ast.GetName(), arch) {
-					s.l.Debug().
-						Str("repo", repoFullName).
-						Str("tag_name", tagName).
-						Str("asset_name", ast.GetName()).
-						Str("arch", arch).
-						Msg("skip asset: name does not match arch")
-					continue
-				}
-
-				s.l.Debug().
-					Str("repo", repoFullName).
-					Str("tag_name", tagName).
-					Str("asset_name", ast.GetName()).
-					Msg("found asset")
-
-				rel.Assets = append(rel.Assets, Asset{
-					Name:   ast.GetName(),
-					Size:   ast.GetSize(),
-					SHA256: s.assetChecksum(ctx, ast.GetBrowserDownloadURL()),
-					URL:    ast.GetBrowserDownloadURL(),
-				})
+			if srcRel.manifest.Notes != "" {
+				rel.Notes = srcRel.manifest.Notes
 			}
+		} else {
+			rel.Assets = s.matchAssets(srcRel.SourceRelease, repoName, astIDs)
+		}
 
-			res.List = append(res.List, rel)
+		rel.Assets = s.checkedAssets(tagName, rel.Assets)
+		rel.Assets = s.verifiedAssets(srcRel, rel.Assets)
+		rel.Deltas = s.releaseDeltas(repoOwner, repoName, src, srcRel, rel.Assets)
+		rel.Assets = s.servedAssets(repoOwner, repoName, src, srcRel.SourceRelease, rel.Assets)
+
+		if exc, ok := res.ExcludedTo(ver); ok {
+			s.l.Debug().
+				Str("repo", repoFullName).
+				Str("tag_name", tagName).
+				Str("versions", exc.Versions).
+				Str("reason", exc.Reason).
+				Msg("release is excluded from updates")
 		}
+
+		res.List = append(res.List, rel)
 	}
 
 	slices.SortFunc(res.List, func(a, b Release) int {
@@ -184,44 +329,65 @@ func (s *Service) List(
 	return res, nil
 }
 
-func (s *Service) assetChecksum(ctx context.Context, url string) string {
-	url += ".sha256"
-
-	if v, ok := s.checkSumCache[url]; ok {
-		return v
+// Channel returns the update channel of the device: the one assigned by the app policy, or the requested one.
+func (s *Service) Channel(owner, name, devID string, requested Channel) Channel {
+	if ch, ok := s.policy.Load().App(owner, name).DeviceChannels[devID]; ok && devID != "" {
+		return ch
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		s.l.Error().Err(err).Msg("failed to create a request for asset checksum")
-		return ""
-	}
+	return requested
+}
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		s.l.Error().Err(err).Str("url", url).Msg("failed to fetch asset checksum")
-		return ""
-	}
+// matchAssets returns the release assets which names match the app and contain one of the asset identifiers.
+func (s *Service) matchAssets(rel SourceRelease, repoName string, astIDs []string) []Asset {
+	res := make([]Asset, 0)
 
-	defer res.Body.Close() //nolint:errcheck // ok
+	for _, ast := range rel.Assets {
+		if isChecksumAsset(ast.Name) ||
+			strings.HasSuffix(ast.Name, sigAssetSuffix) ||
+			strings.HasSuffix(ast.Name, deltaAssetSuffix) {
+			continue
+		}
 
-	if res.StatusCode != http.StatusOK {
-		return ""
-	}
+		if !strings.HasPrefix(ast.Name, repoName) {
+			s.l.Debug().
+				Str("tag_name", rel.Tag).
+				Str("asset_name", ast.Name).
+				Msg("skip asset: name does not match app")
+			continue
+		}
 
-	b, err := io.ReadAll(res.Body)
-	if err != nil {
-		s.l.Error().Err(err).Str("url", url).Msg("failed to read asset checksum")
-		return ""
-	}
+		if !matchAssetName(repoName, ast.Name, astIDs) {
+			s.l.Debug().
+				Str("tag_name", rel.Tag).
+				Str("asset_name", ast.Name).
+				Strs("asset_ids", astIDs).
+				Msg("skip asset: name does not match hardware")
+			continue
+		}
 
-	if len(b) < 64 {
-		s.l.Error().Err(err).Str("url", url).Int("len", len(b)).Msg("asset checksum is too short")
-		return ""
+		s.l.Debug().
+			Str("tag_name", rel.Tag).
+			Str("asset_name", ast.Name).
+			Msg("found asset")
+
+		res = append(res, Asset{
+			Name:   ast.Name,
+			Role:   assetRole(repoName, ast.Name),
+			Size:   ast.Size,
+			SHA256: ast.SHA256,
+			SHA512: ast.SHA512,
+			URL:    ast.URL,
+		})
 	}
 
-	v := string(b)[:64]
-	s.checkSumCache[url] = v
+	return res
+}
+
+func (s *Service) source(owner, name string) ReleaseSource {
+	if src, ok := s.appSrc[owner+"/"+name]; ok {
+		return src
+	}
 
-	return v
+	return s.src
 }
@@ -0,0 +1,222 @@
+package update
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ashep/d5y/internal/clientinfo"
+	"github.com/rs/zerolog"
+)
+
+// newTestReleaseSet lists cronus releases for esp32 devices on the channel under the policy of the app.
+func newTestReleaseSet(t *testing.T, policy string, cfg Config, ch Channel) *ReleaseSet {
+	t.Helper()
+
+	rlsSet, err := newTestService(t, policy, cfg).List(context.Background(), "ashep", "cronus", "esp32", ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rlsSet
+}
+
+// newTestService creates a service serving cronus releases under the policy of the app.
+func newTestService(t *testing.T, policy string, cfg Config) *Service {
+	t.Helper()
+
+	tags := []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0", "2.1.0-alpha1", "2.1.0-beta1"}
+
+	rels := make([]SourceRelease, 0, len(tags))
+	for _, tag := range tags {
+		rels = append(rels, SourceRelease{
+			Tag:    tag,
+			Assets: []SourceAsset{{Name: "cronus-esp32.bin", Ref: tag + "/cronus-esp32.bin"}},
+		})
+	}
+
+	cfg.PolicyFile = filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(cfg.PolicyFile, []byte("apps:\n  ashep/cronus:\n"+policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	src := &memSource{rels: map[string][]SourceRelease{"ashep/cronus": rels}}
+
+	svc, err := New(src, nil, cfg, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc
+}
+
+// nextVersion returns the version of the next release offered to the device, or an empty string.
+func nextVersion(rlsSet *ReleaseSet, current string, dev clientinfo.Info) string {
+	var cur *semver.Version
+	if current != "" {
+		cur = semver.MustParse(current)
+	}
+
+	if next := rlsSet.Next(cur, dev); next != nil {
+		return next.Version.String()
+	}
+
+	return ""
+}
+
+// pathVersions returns versions of the upgrade path of the device.
+func pathVersions(rlsSet *ReleaseSet, current string, dev clientinfo.Info) []string {
+	res := make([]string, 0)
+	for _, rel := range rlsSet.Path(semver.MustParse(current), dev) {
+		res = append(res, rel.Version.String())
+	}
+
+	return res
+}
+
+func TestReleaseSetNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		want    string
+	}{
+		{name: "next release", current: "1.0.0", want: "1.1.0"},
+		{name: "prerelease is not offered", current: "2.0.0"},
+		{name: "unknown current version"},
+		{name: "unreleased current version", current: "1.0.5", want: "1.1.0"},
+	}
+
+	rlsSet := newTestReleaseSet(t, "", Config{}, ChannelStable)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextVersion(rlsSet, tt.current, clientinfo.Info{}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseSetPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		want    []string
+	}{
+		{name: "all releases", current: "1.0.0", want: []string{"1.1.0", "1.2.0", "2.0.0"}},
+		{name: "latest release", current: "2.0.0", want: []string{}},
+		{name: "newer than latest", current: "3.0.0", want: []string{}},
+	}
+
+	rlsSet := newTestReleaseSet(t, "", Config{}, ChannelStable)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pathVersions(rlsSet, tt.current, clientinfo.Info{}); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseSetWaypoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		excludeTo string // applied to the listed set, as policies with excluded waypoints are rejected
+		device    clientinfo.Info
+		current   string
+		wantNext  string
+		wantPath  []string
+	}{
+		{
+			name:     "waypoint",
+			policy:   "    waypoints: [1.2.0]\n",
+			current:  "1.0.0",
+			wantNext: "1.1.0",
+			wantPath: []string{"1.1.0", "1.2.0", "2.0.0"},
+		},
+		{
+			name:     "waypoint not offered",
+			policy:   "    waypoints: [1.2.0]\n    rollouts: {1.2.0: {percent: 0}}\n",
+			device:   clientinfo.Info{ID: "dev1"},
+			current:  "1.0.0",
+			wantNext: "1.1.0",
+			wantPath: []string{"1.1.0"},
+		},
+		{
+			name:     "next waypoint not offered",
+			policy:   "    waypoints: [1.1.0]\n    rollouts: {1.1.0: {percent: 0}}\n",
+			device:   clientinfo.Info{ID: "dev1"},
+			current:  "1.0.0",
+			wantPath: []string{},
+		},
+		{
+			name:      "excluded waypoint",
+			policy:    "    waypoints: [1.2.0]\n",
+			excludeTo: "1.2.0",
+			current:   "1.0.0",
+			wantNext:  "1.1.0",
+			wantPath:  []string{"1.1.0"},
+		},
+		{
+			name:     "passed waypoint",
+			policy:   "    waypoints: [1.1.0]\n",
+			current:  "1.2.0",
+			wantNext: "2.0.0",
+			wantPath: []string{"2.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rlsSet := newTestReleaseSet(t, tt.policy, Config{}, ChannelStable)
+
+			if tt.excludeTo != "" {
+				rlsSet.policy.ExcludeTo = exclusionList{{Versions: tt.excludeTo, Reason: "broken"}}
+				if err := rlsSet.policy.ExcludeTo.compile(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := nextVersion(rlsSet, tt.current, tt.device); got != tt.wantNext {
+				t.Errorf("next: got %q, want %q", got, tt.wantNext)
+			}
+
+			if got := pathVersions(rlsSet, tt.current, tt.device); !slices.Equal(got, tt.wantPath) {
+				t.Errorf("path: got %v, want %v", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestPolicyRejectsExcludedWaypoint(t *testing.T) {
+	p := &Policy{Apps: map[string]AppPolicy{
+		"ashep/cronus": {
+			Waypoints: []string{"1.2.0"},
+			ExcludeTo: exclusionList{{Versions: ">=1.2.0 <1.3.0", Reason: "broken"}},
+		},
+	}}
+
+	if err := p.normalize(); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestReleaseSetNextHalted(t *testing.T) {
+	rlsSet := newTestReleaseSet(t, "", Config{Halter: haltedReleases{"1.1.0"}}, ChannelStable)
+
+	if got := nextVersion(rlsSet, "1.0.0", clientinfo.Info{}); got != "1.2.0" {
+		t.Errorf("got %q, want %q", got, "1.2.0")
+	}
+}
+
+// haltedReleases is a Halter of the listed versions.
+type haltedReleases []string
+
+func (h haltedReleases) Halted(_, _, version string) bool {
+	return slices.Contains(h, version)
+}
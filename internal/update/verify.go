@@ -0,0 +1,84 @@
+package update
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	sigAssetSuffix   = ".sig"
+	maxSigSize       = 1024
+	sigCacheSize     = 4096
+	sigCacheTTL      = time.Hour
+	sigCacheErrorTTL = time.Minute
+)
+
+// AssetVerifier verifies detached signatures published as `{asset}.sig` release assets.
+// A signature is made over the hex encoded SHA256 checksum of the asset.
+type AssetVerifier interface {
+	Verify(msg []byte, sig string) (string, error)
+}
+
+// releaseSignatures fetches detached signatures of the release assets, keyed by asset name.
+func (s *Service) releaseSignatures(ctx context.Context, src ReleaseSource, rel SourceRelease) map[string]string {
+	res := make(map[string]string)
+
+	for _, ast := range rel.Assets {
+		if !strings.HasSuffix(ast.Name, sigAssetSuffix) {
+			continue
+		}
+
+		sig, err := s.sigs.get(ast.Ref, func() (string, error) {
+			return fetchSignature(ctx, src, ast)
+		})
+		if err != nil {
+			s.l.Error().Err(err).Str("tag_name", rel.Tag).Str("ref", ast.Ref).Msg("failed to fetch asset signature")
+			continue
+		}
+
+		res[strings.TrimSuffix(ast.Name, sigAssetSuffix)] = sig
+	}
+
+	return res
+}
+
+func fetchSignature(ctx context.Context, src ReleaseSource, ast SourceAsset) (string, error) {
+	rc, err := src.Fetch(ctx, ast)
+	if err != nil {
+		return "", err
+	}
+
+	defer rc.Close() //nolint:errcheck // ok
+
+	b, err := io.ReadAll(io.LimitReader(rc, maxSigSize))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// verifiedAssets returns the assets which signatures are valid. All assets are returned if verification is off.
+func (s *Service) verifiedAssets(rel catalogRelease, assets []Asset) []Asset {
+	if s.verifier == nil {
+		return assets
+	}
+
+	return slices.DeleteFunc(assets, func(ast Asset) bool {
+		sig, ok := rel.sigs[ast.Name]
+		if !ok || ast.SHA256 == "" {
+			s.l.Warn().Str("tag_name", rel.Tag).Str("asset_name", ast.Name).Msg("skip asset: no signature")
+			return true
+		}
+
+		if _, err := s.verifier.Verify([]byte(strings.ToLower(ast.SHA256)), sig); err != nil {
+			s.l.Error().Err(err).Str("tag_name", rel.Tag).Str("asset_name", ast.Name).Msg("skip asset: bad signature")
+			return true
+		}
+
+		return false
+	})
+}
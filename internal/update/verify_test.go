@@ -0,0 +1,48 @@
+package update
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestReleaseSignaturesCache(t *testing.T) {
+	const ref = "1.0.0/cronus-esp32.bin.sig"
+
+	rel := SourceRelease{
+		Tag:    "1.0.0",
+		Assets: []SourceAsset{{Name: "cronus-esp32.bin.sig", Ref: ref}},
+	}
+
+	src := &memSource{contents: map[string]string{ref: "ci:first\n"}}
+
+	svc, err := New(src, nil, Config{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if sig := svc.releaseSignatures(context.Background(), src, rel)["cronus-esp32.bin"]; sig != "ci:first" {
+			t.Fatalf("got signature %q", sig)
+		}
+	}
+
+	if n := src.fetches(ref); n != 1 {
+		t.Errorf("got %d fetches, want 1", n)
+	}
+
+	// A signature replaced at the same ref is read again once the cached one expires
+	src.contents[ref] = "ci:second\n"
+
+	svc.sigs.mu.Lock()
+	item := svc.sigs.items[ref]
+	item.expires = time.Now().Add(-time.Second)
+	svc.sigs.items[ref] = item
+	svc.sigs.mu.Unlock()
+
+	if sig := svc.releaseSignatures(context.Background(), src, rel)["cronus-esp32.bin"]; sig != "ci:second" {
+		t.Errorf("got signature %q", sig)
+	}
+}
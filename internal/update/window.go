@@ -0,0 +1,109 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ashep/d5y/internal/clientinfo"
+)
+
+// UpdateWindow is the time of day releases are offered at, in the local time of devices.
+// The window may span midnight, e.g. from 22:00 to 04:00.
+type UpdateWindow struct {
+	Start string `yaml:"start"` // `HH:MM`
+	End   string `yaml:"end"`   // `HH:MM`
+
+	// BypassCritical controls whether critical releases are offered outside the window; true if omitted.
+	BypassCritical *bool `yaml:"bypass_critical"`
+
+	start timeOfDay
+	end   timeOfDay
+}
+
+// timeOfDay is a wall clock time. Window bounds are built from it for each day, as days of DST changes are shorter or
+// longer than 24 hours.
+type timeOfDay struct {
+	hour int
+	min  int
+}
+
+func (d timeOfDay) minutes() int {
+	return d.hour*60 + d.min
+}
+
+// on returns the time on the day of t shifted by the number of days.
+func (d timeOfDay) on(t time.Time, days int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, d.hour, d.min, 0, 0, t.Location())
+}
+
+func (w *UpdateWindow) compile() error {
+	var err error
+
+	if w.start, err = parseTimeOfDay(w.Start); err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+
+	if w.end, err = parseTimeOfDay(w.End); err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+
+	if w.start == w.end {
+		return errors.New("empty window")
+	}
+
+	return nil
+}
+
+func parseTimeOfDay(s string) (timeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return timeOfDay{}, err
+	}
+
+	return timeOfDay{hour: t.Hour(), min: t.Minute()}, nil
+}
+
+// wait returns the time left until the window opens, or zero if it is open at t.
+func (w *UpdateWindow) wait(t time.Time) time.Duration {
+	start := w.start.on(t, 0)
+	end := w.end.on(t, 0)
+
+	if w.start.minutes() > w.end.minutes() {
+		// The window spans midnight, so it is closed between the end and the start of the day
+		if t.Before(end) || !t.Before(start) {
+			return 0
+		}
+
+		return start.Sub(t)
+	}
+
+	switch {
+	case t.Before(start):
+		return start.Sub(t)
+	case t.Before(end):
+		return 0
+	default:
+		return w.start.on(t, 1).Sub(t)
+	}
+}
+
+// WindowWait returns the time left until the update window of the app opens for the device, or zero if the release
+// may be offered now. Devices with unknown timezones are assumed to be in UTC.
+func (r ReleaseSet) WindowWait(rel *Release, dev clientinfo.Info, now time.Time) time.Duration {
+	w := r.policy.UpdateWindow
+	if w == nil || rel == nil {
+		return 0
+	}
+
+	if rel.Critical && (w.BypassCritical == nil || *w.BypassCritical) {
+		return 0
+	}
+
+	loc, err := time.LoadLocation(dev.Timezone)
+	if err != nil || dev.Timezone == "" {
+		loc = time.UTC
+	}
+
+	return w.wait(now.In(loc))
+}
@@ -0,0 +1,136 @@
+package update
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ashep/d5y/internal/clientinfo"
+)
+
+func TestReleaseSetWindowWait(t *testing.T) {
+	bypassOff := false
+
+	tests := []struct {
+		name     string
+		window   *UpdateWindow
+		critical bool
+		timezone string
+		date     string // UTC, 2026-01-15 if empty
+		now      string // UTC
+		want     time.Duration
+	}{
+		{
+			name: "no window",
+			now:  "12:00",
+		},
+		{
+			name:   "open",
+			window: &UpdateWindow{Start: "02:00", End: "05:00"},
+			now:    "03:00",
+		},
+		{
+			name:   "before start",
+			window: &UpdateWindow{Start: "02:00", End: "05:00"},
+			now:    "01:30",
+			want:   30 * time.Minute,
+		},
+		{
+			name:   "after end",
+			window: &UpdateWindow{Start: "02:00", End: "05:00"},
+			now:    "05:00",
+			want:   21 * time.Hour,
+		},
+		{
+			name:   "over midnight, open before midnight",
+			window: &UpdateWindow{Start: "22:00", End: "04:00"},
+			now:    "23:00",
+		},
+		{
+			name:   "over midnight, open after midnight",
+			window: &UpdateWindow{Start: "22:00", End: "04:00"},
+			now:    "01:00",
+		},
+		{
+			name:   "over midnight, closed",
+			window: &UpdateWindow{Start: "22:00", End: "04:00"},
+			now:    "12:00",
+			want:   10 * time.Hour,
+		},
+		{
+			name:     "device timezone",
+			window:   &UpdateWindow{Start: "02:00", End: "05:00"},
+			timezone: "Europe/Kyiv", // UTC+2 in winter
+			now:      "01:00",
+		},
+		{
+			name:     "unknown timezone",
+			window:   &UpdateWindow{Start: "02:00", End: "05:00"},
+			timezone: "Nowhere/Nothing",
+			now:      "01:00",
+			want:     time.Hour,
+		},
+		{
+			name:     "critical release",
+			window:   &UpdateWindow{Start: "02:00", End: "05:00"},
+			critical: true,
+			now:      "12:00",
+		},
+		{
+			name:     "critical release without bypass",
+			window:   &UpdateWindow{Start: "02:00", End: "05:00", BypassCritical: &bypassOff},
+			critical: true,
+			now:      "12:00",
+			want:     14 * time.Hour,
+		},
+		{
+			name:     "before start on a DST day",
+			window:   &UpdateWindow{Start: "05:00", End: "06:00"},
+			timezone: "Europe/Kyiv", // clocks go from 03:00 to 04:00 on 2026-03-29
+			date:     "2026-03-28",
+			now:      "23:00", // 01:00 local
+			want:     3 * time.Hour,
+		},
+		{
+			name:     "open on a DST day",
+			window:   &UpdateWindow{Start: "05:00", End: "06:00"},
+			timezone: "Europe/Kyiv",
+			date:     "2026-03-29",
+			now:      "02:30", // 05:30 local
+		},
+		{
+			name:     "after end on a DST day",
+			window:   &UpdateWindow{Start: "05:00", End: "06:00"},
+			timezone: "Europe/Kyiv", // clocks go from 04:00 to 03:00 on 2026-10-25
+			date:     "2026-10-25",
+			now:      "04:00", // 06:00 local
+			want:     23 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.window != nil {
+				if err := tt.window.compile(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			date := tt.date
+			if date == "" {
+				date = "2026-01-15"
+			}
+
+			now, err := time.Parse("2006-01-02 15:04", date+" "+tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rlsSet := ReleaseSet{policy: AppPolicy{UpdateWindow: tt.window}}
+			rel := &Release{Critical: tt.critical}
+
+			if got := rlsSet.WindowWait(rel, clientinfo.Info{Timezone: tt.timezone}, now); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}